@@ -0,0 +1,529 @@
+package gonet
+
+import (
+	"context"
+	"crypto/md5" // nolint gosec
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator authenticates the requests, used by ReqOption.Auth or HTTPReq.Auth.
+// It is run like an Interceptor, inside the retrying and outside the other interceptors,
+// so it can replay the request for the challenges.
+type Authenticator interface {
+	Authenticate(req *http.Request, next Invoker) (*http.Response, error)
+}
+
+// AuthenticatorFunc adapts the function to the Authenticator.
+type AuthenticatorFunc func(req *http.Request, next Invoker) (*http.Response, error)
+
+// Authenticate calls f(req, next).
+func (f AuthenticatorFunc) Authenticate(req *http.Request, next Invoker) (*http.Response, error) {
+	return f(req, next)
+}
+
+// Auth sets the authenticator of the request.
+func (b *HTTPReq) Auth(auth Authenticator) *HTTPReq {
+	b.setting.Auth = auth
+
+	return b
+}
+
+// BasicAuth authenticates the requests by the HTTP Basic Authentication.
+type BasicAuth struct {
+	Username, Password string
+}
+
+// Authenticate sets the Authorization header.
+func (a BasicAuth) Authenticate(req *http.Request, next Invoker) (*http.Response, error) {
+	req.SetBasicAuth(a.Username, a.Password)
+
+	return next(req)
+}
+
+// BearerAuth authenticates the requests by the static bearer token.
+type BearerAuth struct {
+	Token string
+}
+
+// Authenticate sets the Authorization header.
+func (a BearerAuth) Authenticate(req *http.Request, next Invoker) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	return next(req)
+}
+
+// DigestAuth authenticates the requests by the HTTP Digest Access Authentication (RFC 7616).
+// The first request is sent without the credentials to get the challenge from the 401 response,
+// and then replayed with the credentials. The challenge is kept for the following requests.
+// Algorithms MD5, SHA-256, SHA-512-256 and their -sess variants are supported with the qop auth.
+type DigestAuth struct {
+	Username, Password string
+
+	lock      sync.Mutex
+	challenge *digestChallenge
+	nc        int
+}
+
+// NewDigestAuth creates the DigestAuth.
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{Username: username, Password: password}
+}
+
+// Authenticate handles the challenge round trip.
+func (a *DigestAuth) Authenticate(req *http.Request, next Invoker) (*http.Response, error) {
+	known := false
+
+	if authorization, ok := a.authorize(req); ok {
+		req.Header.Set("Authorization", authorization)
+
+		known = true
+	}
+
+	resp, err := next(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	c := parseDigestChallenges(resp.Header.Values("WWW-Authenticate"))
+	if c == nil || known && !c.stale && !a.newNonce(c) {
+		return resp, nil // the credentials are rejected
+	}
+
+	a.lock.Lock()
+	a.challenge, a.nc = c, 0
+	a.lock.Unlock()
+
+	if !replayable(req) || RewindBody(req) != nil {
+		return resp, nil // the body sent can not be sent again
+	}
+
+	authorization, ok := a.authorize(req)
+	if !ok {
+		return resp, nil
+	}
+
+	closeBody(resp)
+	req.Header.Set("Authorization", authorization)
+
+	return next(req)
+}
+
+func (a *DigestAuth) newNonce(c *digestChallenge) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.challenge == nil || a.challenge.nonce != c.nonce
+}
+
+// authorize computes the Authorization header of the request by the kept challenge.
+func (a *DigestAuth) authorize(req *http.Request) (string, bool) {
+	a.lock.Lock()
+	c := a.challenge
+	a.nc++
+	nc := a.nc
+	a.lock.Unlock()
+
+	if c == nil {
+		return "", false
+	}
+
+	h := digestHash(c.algorithm)
+	if h == nil {
+		return "", false
+	}
+
+	cnonce := newCnonce()
+	uri := req.URL.RequestURI()
+
+	ha1 := hashHex(h, a.Username+":"+c.realm+":"+a.Password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = hashHex(h, ha1+":"+c.nonce+":"+cnonce)
+	}
+
+	ha2 := hashHex(h, req.Method+":"+uri)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `Digest username=%q, realm=%q, nonce=%q, uri=%q`, a.Username, c.realm, c.nonce, uri)
+
+	if c.qop {
+		ncHex := fmt.Sprintf("%08x", nc)
+		response := hashHex(h, ha1+":"+c.nonce+":"+ncHex+":"+cnonce+":auth:"+ha2)
+		fmt.Fprintf(&b, `, response=%q, qop=auth, nc=%s, cnonce=%q`, response, ncHex, cnonce)
+	} else {
+		fmt.Fprintf(&b, `, response=%q`, hashHex(h, ha1+":"+c.nonce+":"+ha2))
+	}
+
+	if c.algorithm != "" {
+		fmt.Fprintf(&b, `, algorithm=%s`, c.algorithm)
+	}
+
+	if c.opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%q`, c.opaque)
+	}
+
+	return b.String(), true
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm string
+	// qop tells the qop auth is supported.
+	qop   bool
+	stale bool
+}
+
+// parseDigestChallenges parses the Digest challenges of the WWW-Authenticate headers,
+// and returns the one of the strongest supported algorithm.
+func parseDigestChallenges(values []string) *digestChallenge {
+	var best *digestChallenge
+
+	for _, v := range values {
+		for _, challenge := range splitChallenges(v) {
+			scheme, params := challenge[0], challenge[1:]
+			if !strings.EqualFold(scheme, "Digest") {
+				continue
+			}
+
+			c := &digestChallenge{}
+
+			for i := 0; i+1 < len(params); i += 2 {
+				switch value := params[i+1]; strings.ToLower(params[i]) {
+				case "realm":
+					c.realm = value
+				case "nonce":
+					c.nonce = value
+				case "opaque":
+					c.opaque = value
+				case "algorithm":
+					c.algorithm = value
+				case "stale":
+					c.stale = strings.EqualFold(value, "true")
+				case "qop":
+					for _, q := range strings.Split(value, ",") {
+						c.qop = c.qop || strings.TrimSpace(q) == "auth"
+					}
+				}
+			}
+
+			if digestHash(c.algorithm) != nil && (best == nil || digestRank(c.algorithm) > digestRank(best.algorithm)) {
+				best = c
+			}
+		}
+	}
+
+	return best
+}
+
+func digestRank(algorithm string) int {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "SHA-512-256":
+		return 3 // nolint gomnd
+	case "SHA-256":
+		return 2 // nolint gomnd
+	default:
+		return 1
+	}
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	default:
+		return nil
+	}
+}
+
+func hashHex(h func() hash.Hash, s string) string {
+	d := h()
+	_, _ = d.Write([]byte(s))
+
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+func newCnonce() string {
+	b := make([]byte, 16) // nolint gomnd
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// splitChallenges splits the WWW-Authenticate header value into the challenges,
+// every challenge is the auth scheme followed by the name and value pairs of its parameters.
+func splitChallenges(s string) [][]string {
+	var (
+		challenges [][]string
+		current    []string
+	)
+
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, " \t,") {
+		token := s
+		if p := strings.IndexAny(s, " \t,="); p >= 0 {
+			token = s[:p]
+		}
+
+		s = strings.TrimLeft(s[len(token):], " \t")
+
+		if !strings.HasPrefix(s, "=") { // a new auth scheme
+			if current != nil {
+				challenges = append(challenges, current)
+			}
+
+			current = []string{token}
+
+			continue
+		}
+
+		s = strings.TrimLeft(s[1:], " \t")
+
+		var value string
+		value, s = readParamValue(s)
+
+		if current != nil {
+			current = append(current, token, value)
+		}
+	}
+
+	if current != nil {
+		challenges = append(challenges, current)
+	}
+
+	return challenges
+}
+
+// readParamValue reads the token or the quoted string, and returns the value and the remaining.
+func readParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		p := strings.IndexAny(s, " \t,")
+		if p < 0 {
+			return s, ""
+		}
+
+		return s[:p], s[p:]
+	}
+
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), ""
+}
+
+// DefaultTokenExpiryDelta is the default time before the expiry to refresh the OAuth2 tokens.
+const DefaultTokenExpiryDelta = 10 * time.Second
+
+// ClientCredentials authenticates the requests by the OAuth2 client credentials grant (RFC 6749 4.4).
+// The access token is cached, refreshed before the expiry, and refreshed once when the request gets 401.
+type ClientCredentials struct {
+	// TokenURL is the url of the token endpoint.
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams are the additional parameters of the token requests.
+	EndpointParams url.Values
+	// AuthInParams sends the client credentials by the parameters instead of the HTTP Basic Authentication.
+	AuthInParams bool
+	// Option is the option of the token requests, NewReqOption() will be used if nil.
+	Option *ReqOption
+	// ExpiryDelta is the time before the expiry to refresh the token, DefaultTokenExpiryDelta by default.
+	ExpiryDelta time.Duration
+
+	lock       sync.Mutex
+	token      *OAuth2Token
+	expiry     time.Time
+	refreshing chan struct{}
+}
+
+// OAuth2Token is the token response of the OAuth2 token endpoint.
+type OAuth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime in seconds of the token, zero for no expiry.
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// Authorization returns the Authorization header value of the token.
+func (t OAuth2Token) Authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+
+	return t.TokenType + " " + t.AccessToken
+}
+
+// Authenticate sets the Authorization header by the cached token, and refreshes the token once for the 401.
+func (a *ClientCredentials) Authenticate(req *http.Request, next Invoker) (*http.Response, error) {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", token.Authorization())
+
+	resp, err := next(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	a.invalidate(token)
+
+	if token, err = a.Token(req.Context()); err != nil {
+		return resp, nil
+	}
+
+	if !replayable(req) || RewindBody(req) != nil {
+		return resp, nil // the body sent can not be sent again
+	}
+
+	closeBody(resp)
+	req.Header.Set("Authorization", token.Authorization())
+
+	return next(req)
+}
+
+// Token returns the cached token, or requests a new one if it is absent or about to expire.
+// The token is requested outside the lock, and the concurrent callers wait for the same request.
+func (a *ClientCredentials) Token(ctx context.Context) (*OAuth2Token, error) {
+	for {
+		a.lock.Lock()
+
+		if a.valid() {
+			token := a.token
+			a.lock.Unlock()
+
+			return token, nil
+		}
+
+		if refreshing := a.refreshing; refreshing != nil {
+			a.lock.Unlock()
+
+			select {
+			case <-refreshing:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		refreshing := make(chan struct{})
+		a.refreshing = refreshing
+		a.lock.Unlock()
+
+		return a.refresh(ctx, refreshing)
+	}
+}
+
+// valid tells the cached token is present and not about to expire, a.lock should be held.
+func (a *ClientCredentials) valid() bool {
+	delta := a.ExpiryDelta
+	if delta <= 0 {
+		delta = DefaultTokenExpiryDelta
+	}
+
+	return a.token != nil && (a.expiry.IsZero() || time.Now().Add(delta).Before(a.expiry))
+}
+
+// refresh requests a new token and caches it, the refreshing is closed when it is done.
+func (a *ClientCredentials) refresh(ctx context.Context, refreshing chan struct{}) (*OAuth2Token, error) {
+	token, err := a.requestToken(ctx)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.refreshing = nil
+	close(refreshing)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.token, a.expiry = token, time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// invalidate drops the cached token if it is still the rejected one.
+func (a *ClientCredentials) invalidate(token *OAuth2Token) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token == token {
+		a.token = nil
+	}
+}
+
+func (a *ClientCredentials) requestToken(ctx context.Context) (*OAuth2Token, error) {
+	option := a.Option
+	if option == nil {
+		option = NewReqOption()
+	} else if option.Auth == a { // the token requests can not wait for the token itself.
+		o := *option
+		o.Auth = nil
+		option = &o
+	}
+
+	req, err := option.PostCtx(ctx, a.TokenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Param("grant_type", "client_credentials").Accept(MediaTypeJSON)
+
+	if len(a.Scopes) > 0 {
+		req.Param("scope", strings.Join(a.Scopes, " "))
+	}
+
+	for k, values := range a.EndpointParams {
+		for _, v := range values {
+			req.AddParam(k, v)
+		}
+	}
+
+	if a.AuthInParams {
+		req.Param("client_id", a.ClientID).Param("client_secret", a.ClientSecret)
+	} else {
+		req.BasicAuth(url.QueryEscape(a.ClientID), url.QueryEscape(a.ClientSecret))
+	}
+
+	var token OAuth2Token
+	if err := req.ToJSON(&token); err != nil {
+		return nil, fmt.Errorf("oauth2 token: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2 token: no access_token in the response of %s", a.TokenURL)
+	}
+
+	return &token, nil
+}
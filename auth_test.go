@@ -0,0 +1,229 @@
+package gonet
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	str, err := MustGet(ts.URL).Auth(BearerAuth{Token: "t0k3n"}).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer t0k3n", str)
+
+	option := NewReqOption()
+	option.Auth = BasicAuth{Username: "bingoo", Password: "huang"}
+
+	str, err = option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "Basic YmluZ29vOmh1YW5n", str)
+}
+
+func digestServer(challenges *int32) *httptest.Server {
+	const realm, nonce, password = "test@gonet", "n0nce", "s3cret"
+
+	h := func(s string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(s))) }
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		challenge := splitChallenges(r.Header.Get("Authorization"))
+		if len(challenge) == 0 || challenge[0][0] != "Digest" {
+			atomic.AddInt32(challenges, 1)
+			w.Header().Add("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth, auth-int", algorithm=MD5, nonce="`+nonce+`"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth, auth-int", algorithm=SHA-256, nonce="`+nonce+`", opaque="op"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		p := map[string]string{}
+		for i := 1; i+1 < len(challenge[0]); i += 2 {
+			p[challenge[0][i]] = challenge[0][i+1]
+		}
+
+		ha1 := h(p["username"] + ":" + realm + ":" + password)
+		ha2 := h(r.Method + ":" + r.URL.RequestURI())
+		expected := h(ha1 + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+
+		if p["algorithm"] != "SHA-256" || p["opaque"] != "op" || p["response"] != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(p["nc"] + " " + string(body)))
+	}))
+}
+
+func TestDigestAuth(t *testing.T) {
+	var challenges int32
+
+	ts := digestServer(&challenges)
+	defer ts.Close()
+
+	auth := NewDigestAuth("bingoo", "s3cret")
+
+	req := MustPost(ts.URL + "/dir/index.html?a=1").Auth(auth)
+	req.Body("hello")
+
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "00000001 hello", str)
+
+	// the challenge is kept for the following requests.
+	str, err = MustGet(ts.URL + "/other").Auth(auth).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "00000002 ", str)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&challenges))
+
+	resp, err := MustGet(ts.URL).Auth(NewDigestAuth("bingoo", "wrong")).Response()
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// the body which can not be replayed is not sent again, but the challenge is kept.
+	auth = NewDigestAuth("bingoo", "s3cret")
+	req = MustPost(ts.URL).Auth(auth)
+	req.req.Body, req.req.ContentLength = ioutil.NopCloser(strings.NewReader("hello")), -1
+
+	resp, err = req.Response()
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	str, err = MustPost(ts.URL).Auth(auth).Body("hello").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "00000001 hello", str)
+}
+
+func TestClientCredentials(t *testing.T) {
+	var tokens int32
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&tokens, 1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", n), "token_type": "bearer", "expires_in": 3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	// the server revokes token1 after its first use.
+	var used int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "Bearer token1" && atomic.AddInt32(&used, 1) > 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(strings.TrimPrefix(authorization, "Bearer ")))
+	}))
+	defer ts.Close()
+
+	auth := &ClientCredentials{
+		TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "secret", Scopes: []string{"read", "write"},
+	}
+
+	option := NewReqOption()
+	option.Auth = auth
+
+	str, err := option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "token1", str)
+
+	// refreshed on the 401.
+	str, err = option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "token2", str)
+
+	// cached.
+	str, err = option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "token2", str)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokens))
+
+	// refreshed before the expiry.
+	auth.ExpiryDelta = 2 * time.Hour
+
+	str, err = option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "token3", str)
+
+	auth.ClientSecret = "bad"
+	auth.token = nil
+
+	_, err = option.MustGet(ts.URL).String()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "oauth2 token")
+}
+
+func TestClientCredentialsConcurrent(t *testing.T) {
+	var tokens int32
+
+	release := make(chan struct{})
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", atomic.AddInt32(&tokens, 1)), "token_type": "bearer",
+		})
+	}))
+	defer tokenServer.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")))
+	}))
+	defer ts.Close()
+
+	option := NewReqOption()
+	// the token endpoint shares the option, and so the authenticator.
+	option.Auth = &ClientCredentials{TokenURL: tokenServer.URL, ClientID: "client", Option: option}
+
+	const n = 5
+
+	var wg sync.WaitGroup
+
+	results := make([]string, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i], _ = option.MustGet(ts.URL).String()
+		}(i)
+	}
+
+	time.Sleep(100 * time.Millisecond) // nolint gomnd
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []string{"token1", "token1", "token1", "token1", "token1"}, results)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens))
+}
@@ -0,0 +1,223 @@
+package gonet
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// The media types of the built-in codecs.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeXML  = "application/xml"
+	MediaTypeForm = "application/x-www-form-urlencoded"
+	MediaTypeText = "text/plain"
+)
+
+// Codec encodes the request bodies and decodes the response bodies of a media type.
+type Codec interface {
+	// ContentType returns the Content-Type header of the encoded bodies, like application/json;charset=utf-8.
+	ContentType() string
+	// Marshal encodes the v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the data into v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// nolint gochecknoglobals
+var (
+	codecs = map[string]Codec{
+		MediaTypeJSON: JSONCodec{},
+		MediaTypeXML:  XMLCodec{},
+		"text/xml":    XMLCodec{},
+		MediaTypeForm: FormCodec{},
+		MediaTypeText: TextCodec{},
+	}
+	codecsLock sync.RWMutex
+)
+
+// RegisterCodec registers the codec of the media type, like application/msgpack,
+// replacing the existing one.
+func RegisterCodec(mediaType string, codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	codecs[strings.ToLower(mediaType)] = codec
+}
+
+// LookupCodec looks up the codec by the media type or the Content-Type, like application/json; charset=utf-8.
+// The structured syntax suffixes, like application/problem+json, fall back to the codecs of json or xml.
+func LookupCodec(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	if c, ok := codecs[mediaType]; ok {
+		return c, true
+	}
+
+	if p := strings.LastIndexByte(mediaType, '+'); p >= 0 {
+		c, ok := codecs["application/"+mediaType[p+1:]]
+		return c, ok
+	}
+
+	return nil, false
+}
+
+// DecodeBody decodes the data into v by the codec of the Content-Type.
+// JSON is assumed when the Content-Type is missing or unknown,
+// also for the text/plain to decode into the non-text v, because many servers send JSON as text.
+func DecodeBody(contentType string, data []byte, v interface{}) error {
+	c, ok := LookupCodec(contentType)
+	if !ok {
+		c = JSONCodec{}
+	} else if _, text := c.(TextCodec); text && !isTextTarget(v) {
+		c = JSONCodec{}
+	}
+
+	return c.Unmarshal(data, v)
+}
+
+// EncodeBody encodes the v by the codec of the media type, and returns the Content-Type together.
+func EncodeBody(mediaType string, v interface{}) ([]byte, string, error) {
+	c, ok := LookupCodec(mediaType)
+	if !ok {
+		return nil, "", fmt.Errorf("no codec registered for %s", mediaType)
+	}
+
+	data, err := c.Marshal(v)
+
+	return data, c.ContentType(), err
+}
+
+// JSONCodec is the codec of application/json.
+type JSONCodec struct{}
+
+// ContentType returns the Content-Type of JSON.
+func (JSONCodec) ContentType() string { return "application/json;charset=utf-8" }
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes the JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// XMLCodec is the codec of application/xml.
+type XMLCodec struct{}
+
+// ContentType returns the Content-Type of XML.
+func (XMLCodec) ContentType() string { return "application/xml;charset=utf-8" }
+
+// Marshal encodes v as XML.
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) { return xml.Marshal(v) }
+
+// Unmarshal decodes the XML data into v.
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+// FormCodec is the codec of application/x-www-form-urlencoded,
+// which encodes url.Values, map[string]string and map[string][]string,
+// and decodes into *url.Values, *map[string]string and *map[string][]string.
+type FormCodec struct{}
+
+// ContentType returns the Content-Type of the urlencoded form.
+func (FormCodec) ContentType() string { return MediaTypeForm }
+
+// Marshal encodes v as the urlencoded form.
+func (FormCodec) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case url.Values:
+		return []byte(t.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(t).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(t))
+		for k, s := range t {
+			values.Set(k, s)
+		}
+
+		return []byte(values.Encode()), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %T for the form encoding", v)
+}
+
+// Unmarshal decodes the urlencoded form data into v.
+func (FormCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case *url.Values:
+		*t = values
+	case *map[string][]string:
+		*t = values
+	case *map[string]string:
+		*t = make(map[string]string, len(values))
+		for k := range values {
+			(*t)[k] = values.Get(k)
+		}
+	default:
+		return fmt.Errorf("unsupported type %T for the form decoding", v)
+	}
+
+	return nil
+}
+
+// TextCodec is the codec of text/plain, which encodes string, []byte, encoding.TextMarshaler and fmt.Stringer,
+// and decodes into *string, *[]byte and encoding.TextUnmarshaler.
+type TextCodec struct{}
+
+// ContentType returns the Content-Type of the plain text.
+func (TextCodec) ContentType() string { return "text/plain;charset=utf-8" }
+
+// Marshal encodes v as the plain text.
+func (TextCodec) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return []byte(t), nil
+	case []byte:
+		return t, nil
+	case encoding.TextMarshaler:
+		return t.MarshalText()
+	case fmt.Stringer:
+		return []byte(t.String()), nil
+	}
+
+	return []byte(fmt.Sprintf("%v", v)), nil
+}
+
+// Unmarshal decodes the plain text data into v.
+func (TextCodec) Unmarshal(data []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *string:
+		*t = string(data)
+	case *[]byte:
+		*t = append((*t)[:0], data...)
+	case encoding.TextUnmarshaler:
+		return t.UnmarshalText(data)
+	default:
+		return fmt.Errorf("unsupported type %T for the text decoding", v)
+	}
+
+	return nil
+}
+
+func isTextTarget(v interface{}) bool {
+	switch v.(type) {
+	case *string, *[]byte, encoding.TextUnmarshaler:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+package gonet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type upperCodec struct{ TextCodec }
+
+func (upperCodec) ContentType() string { return "text/x-upper" }
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return bytes.ToUpper([]byte(v.(string))), nil
+}
+
+func TestLookupCodec(t *testing.T) {
+	c, ok := LookupCodec("application/problem+json; charset=utf-8")
+	assert.True(t, ok)
+	assert.Equal(t, JSONCodec{}, c)
+
+	c, ok = LookupCodec("Text/XML")
+	assert.True(t, ok)
+	assert.Equal(t, XMLCodec{}, c)
+
+	_, ok = LookupCodec("application/x-upper")
+	assert.False(t, ok)
+
+	RegisterCodec("application/X-Upper", upperCodec{})
+	t.Cleanup(func() {
+		codecsLock.Lock()
+		delete(codecs, "application/x-upper")
+		codecsLock.Unlock()
+	})
+
+	data, contentType, err := EncodeBody("application/x-upper", "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, "ABC", string(data))
+	assert.Equal(t, "text/x-upper", contentType)
+
+	var m map[string]string
+
+	assert.Nil(t, DecodeBody("application/x-www-form-urlencoded", []byte("a=1&b=2&a=3"), &m))
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, m)
+
+	var s struct{ Name string }
+
+	// JSON sent as text
+	assert.Nil(t, DecodeBody("text/plain", []byte(`{"Name":"bingoo"}`), &s))
+	assert.Equal(t, "bingoo", s.Name)
+}
+
+type codecPerson struct {
+	XMLName xml.Name `xml:"person"`
+	Name    string   `xml:"name"`
+}
+
+func TestEncodeDecodeBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Accept"))
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	req := MustPost(ts.URL).Accept(MediaTypeXML)
+	assert.Nil(t, req.EncodeBody(MediaTypeXML, codecPerson{Name: "bingoo"}))
+
+	var p codecPerson
+
+	assert.Nil(t, req.Decode(&p))
+	assert.Equal(t, "bingoo", p.Name)
+
+	rsp, _ := req.Response()
+	assert.Equal(t, "application/xml;charset=utf-8", rsp.Header.Get("X-Content-Type"))
+
+	var values url.Values
+
+	req = MustPost(ts.URL).Accept(MediaTypeForm).Param("a", "1").Param("a", "2")
+	assert.Nil(t, req.Decode(&values))
+	assert.Equal(t, url.Values{"a": {"1", "2"}}, values)
+}
@@ -0,0 +1,445 @@
+package gonet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PublicSuffixList provides the public suffixes of the domains, like golang.org/x/net/publicsuffix.List,
+// which rejects the cookies set for the public suffixes like .com or .co.uk.
+type PublicSuffixList = cookiejar.PublicSuffixList
+
+// StoredCookie is the cookie stored in the PersistentJar.
+type StoredCookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	// Expires is the expiry time, zero for the session cookies.
+	Expires  time.Time     `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"` // nolint golint
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+	// HostOnly tells the cookie is sent to the exact host only, not the sub domains.
+	HostOnly bool      `json:"hostOnly,omitempty"`
+	Creation time.Time `json:"creation"`
+}
+
+// Persistent tells the cookie has an expiry time.
+func (c StoredCookie) Persistent() bool { return !c.Expires.IsZero() }
+
+func (c StoredCookie) id() string { return c.Name + ";" + c.Domain + ";" + c.Path }
+
+func (c StoredCookie) expired(now time.Time) bool { return c.Persistent() && !now.Before(c.Expires) }
+
+// PersistentJar is the http.CookieJar which loads the cookies from a JSON file, and saves them on every change.
+// It can be used by HTTPReq.Jar, ReqOption.Jar or http.Client.Jar.
+// The session cookies, without the expiry time, are saved too unless DropSessionCookies,
+// and the errors of saving on SetCookies, which returns nothing, are reported to the OnError.
+type PersistentJar struct {
+	// Filename is the JSON file of the cookies, the cookies are kept in memory only if empty.
+	Filename string
+	// PSL is the public suffix list, the cookies for any domain are allowed if nil.
+	PSL PublicSuffixList
+	// DropSessionCookies drops the session cookies without the expiry time on saving, like the browsers on exit.
+	// They are saved by default, so the login sessions survive the restarts.
+	DropSessionCookies bool
+	// OnError is called with the errors of saving the file on SetCookies, they are ignored if nil.
+	OnError func(err error)
+	// Now returns the current time, time.Now will be used if nil.
+	Now func() time.Time
+
+	lock    sync.Mutex
+	cookies map[string]StoredCookie
+}
+
+// NewPersistentJar creates the PersistentJar of the file, and loads the cookies if the file exists.
+func NewPersistentJar(filename string, psl PublicSuffixList) (*PersistentJar, error) {
+	j := &PersistentJar{Filename: filename, PSL: psl}
+	if err := j.Load(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *PersistentJar) now() time.Time {
+	if j.Now != nil {
+		return j.Now()
+	}
+
+	return time.Now()
+}
+
+// Load loads the cookies from the file, the unexpired ones replace the ones in memory.
+// It is no-op if the file does not exist.
+func (j *PersistentJar) Load() error {
+	if j.Filename == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(j.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var cookies []StoredCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return fmt.Errorf("load cookies from %s: %w", j.Filename, err)
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.cookies = make(map[string]StoredCookie, len(cookies))
+	now := j.now()
+
+	for _, c := range cookies {
+		if !c.expired(now) {
+			j.cookies[c.id()] = c
+		}
+	}
+
+	return nil
+}
+
+// Save saves the cookies to the file by writing a temporary file and renaming it.
+func (j *PersistentJar) Save() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.save()
+}
+
+func (j *PersistentJar) save() error {
+	if j.Filename == "" {
+		return nil
+	}
+
+	now := j.now()
+	cookies := make([]StoredCookie, 0, len(j.cookies))
+
+	for _, c := range j.cookies {
+		if !c.expired(now) && (c.Persistent() || !j.DropSessionCookies) {
+			cookies = append(cookies, c)
+		}
+	}
+
+	sortCookies(cookies)
+
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(j.Filename), filepath.Base(j.Filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), j.Filename)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// SetCookies implements the http.CookieJar interface.
+// The cookies for the other domains, the public suffixes, or the Secure ones by the insecure urls are rejected.
+func (j *PersistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.cookies == nil {
+		j.cookies = make(map[string]StoredCookie)
+	}
+
+	now := j.now()
+	changed := false
+
+	for _, hc := range cookies {
+		c, ok := j.newStoredCookie(u, host, hc, now)
+		if !ok {
+			continue
+		}
+
+		id := c.id()
+		old, exists := j.cookies[id]
+
+		if hc.MaxAge < 0 || c.expired(now) {
+			if exists {
+				delete(j.cookies, id)
+
+				changed = true
+			}
+
+			continue
+		}
+
+		if exists {
+			c.Creation = old.Creation
+		}
+
+		j.cookies[id] = c
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+
+	if err := j.save(); err != nil && j.OnError != nil {
+		j.OnError(fmt.Errorf("save cookies to %s: %w", j.Filename, err))
+	}
+}
+
+func (j *PersistentJar) newStoredCookie(u *url.URL, host string, hc *http.Cookie, now time.Time) (StoredCookie, bool) {
+	c := StoredCookie{
+		Name: hc.Name, Value: hc.Value, Path: hc.Path,
+		Secure: hc.Secure, HttpOnly: hc.HttpOnly, SameSite: hc.SameSite, Creation: now,
+	}
+
+	if c.Secure && !secureScheme(u.Scheme) {
+		return c, false
+	}
+
+	if c.Path == "" || c.Path[0] != '/' {
+		c.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case hc.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+	case hc.MaxAge < 0:
+		c.Expires = now
+	case !hc.Expires.IsZero():
+		c.Expires = hc.Expires
+	}
+
+	domain := canonicalHost(strings.TrimPrefix(hc.Domain, "."))
+
+	switch {
+	case domain == "" || domain == host && net.ParseIP(host) != nil:
+		c.Domain, c.HostOnly = host, true
+	case net.ParseIP(host) != nil:
+		return c, false
+	case j.PSL != nil && j.PSL.PublicSuffix(domain) == domain:
+		if domain != host {
+			return c, false
+		}
+
+		c.Domain, c.HostOnly = host, true
+	case domain == host || strings.HasSuffix(host, "."+domain):
+		c.Domain = domain
+	default:
+		return c, false
+	}
+
+	return c, true
+}
+
+// Cookies implements the http.CookieJar interface.
+func (j *PersistentJar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return nil
+	}
+
+	secure := secureScheme(u.Scheme)
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := j.now()
+
+	var matched []StoredCookie
+
+	for id, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, id)
+			continue
+		}
+
+		if c.Secure && !secure || !c.domainMatch(host) || !cookiePathMatch(path, c.Path) {
+			continue
+		}
+
+		matched = append(matched, c)
+	}
+
+	sortCookies(matched)
+
+	cookies := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+
+	return cookies
+}
+
+func (c StoredCookie) domainMatch(host string) bool {
+	if c.HostOnly {
+		return host == c.Domain
+	}
+
+	return host == c.Domain || strings.HasSuffix(host, "."+c.Domain)
+}
+
+// List lists the unexpired cookies of the domain and its sub domains, all the cookies for the empty domain.
+func (j *PersistentJar) List(domain string) []StoredCookie {
+	domain = canonicalHost(strings.TrimPrefix(domain, "."))
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := j.now()
+
+	var cookies []StoredCookie
+
+	for _, c := range j.cookies {
+		if !c.expired(now) && inDomain(c.Domain, domain) {
+			cookies = append(cookies, c)
+		}
+	}
+
+	sortCookies(cookies)
+
+	return cookies
+}
+
+// Export writes the unexpired cookies of the domain like List in the Netscape cookies.txt format,
+// which can be read by curl -b or wget --load-cookies.
+func (j *PersistentJar) Export(w io.Writer, domain string) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("# Netscape HTTP Cookie File\n")
+
+	for _, c := range j.List(domain) {
+		d, expires := c.Domain, int64(0)
+		if !c.HostOnly {
+			d = "." + d
+		}
+
+		if c.HttpOnly {
+			d = "#HttpOnly_" + d
+		}
+
+		if c.Persistent() {
+			expires = c.Expires.Unix()
+		}
+
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			d, upperBool(!c.HostOnly), c.Path, upperBool(c.Secure), expires, c.Name, c.Value)
+	}
+
+	return bw.Flush()
+}
+
+// Clear deletes the cookies of the domain and its sub domains, all the cookies for the empty domain,
+// and saves the file.
+func (j *PersistentJar) Clear(domain string) error {
+	domain = canonicalHost(strings.TrimPrefix(domain, "."))
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for id, c := range j.cookies {
+		if inDomain(c.Domain, domain) {
+			delete(j.cookies, id)
+		}
+	}
+
+	return j.save()
+}
+
+func inDomain(cookieDomain, domain string) bool {
+	return domain == "" || cookieDomain == domain || strings.HasSuffix(cookieDomain, "."+domain)
+}
+
+func upperBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+// sortCookies sorts the cookies by the longer paths first, then the earlier creation first, as RFC 6265 5.4.
+func sortCookies(cookies []StoredCookie) {
+	sort.SliceStable(cookies, func(i, j int) bool {
+		if len(cookies[i].Path) != len(cookies[j].Path) {
+			return len(cookies[i].Path) > len(cookies[j].Path)
+		}
+
+		if !cookies[i].Creation.Equal(cookies[j].Creation) {
+			return cookies[i].Creation.Before(cookies[j].Creation)
+		}
+
+		return cookies[i].id() < cookies[j].id()
+	})
+}
+
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func secureScheme(scheme string) bool {
+	return scheme == "https" || scheme == "wss"
+}
+
+// defaultCookiePath returns the default path of the cookies by the request path, as RFC 6265 5.1.4.
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+// cookiePathMatch matches the request path and the cookie path, as RFC 6265 5.1.4.
+func cookiePathMatch(path, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+
+	return strings.HasPrefix(path, cookiePath) &&
+		(strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/')
+}
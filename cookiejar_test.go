@@ -0,0 +1,140 @@
+package gonet
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPSL struct{}
+
+func (testPSL) String() string { return "test" }
+
+func (testPSL) PublicSuffix(domain string) string {
+	if strings.HasSuffix(domain, ".co.uk") || domain == "co.uk" {
+		return "co.uk"
+	}
+
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func TestPersistentJar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", HttpOnly: true, MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Value: "t1"})
+
+			return
+		}
+
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(c.Value))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+
+	jar, err := NewPersistentJar(filename, nil)
+	assert.Nil(t, err)
+
+	_, err = MustGet(ts.URL + "/login").Jar(jar).String()
+	assert.Nil(t, err)
+	assert.Len(t, jar.List(""), 2)
+
+	// the restarted tool loads the cookies, with the session cookie tmp.
+	jar2, err := NewPersistentJar(filename, nil)
+	assert.Nil(t, err)
+	assert.Len(t, jar2.List("127.0.0.1"), 2)
+
+	// the session cookie tmp is dropped by the DropSessionCookies.
+	jar2.DropSessionCookies = true
+	assert.Nil(t, jar2.Save())
+
+	jar2, err = NewPersistentJar(filename, nil)
+	assert.Nil(t, err)
+
+	cookies := jar2.List("127.0.0.1")
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "session", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].HostOnly)
+
+	str, err := MustGet(ts.URL + "/me").Jar(jar2).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "s1", str)
+
+	assert.Nil(t, jar2.Clear("127.0.0.1"))
+
+	jar3, err := NewPersistentJar(filename, nil)
+	assert.Nil(t, err)
+	assert.Len(t, jar3.List(""), 0)
+
+	// the errors of saving are reported to the OnError.
+	var saveErr error
+
+	jar3.Filename = filepath.Join(t.TempDir(), "missing", "cookies.json")
+	jar3.OnError = func(err error) { saveErr = err }
+
+	_, err = MustGet(ts.URL + "/login").Jar(jar3).String()
+	assert.Nil(t, err)
+	assert.NotNil(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "save cookies to "+jar3.Filename)
+}
+
+func TestPersistentJarRules(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	jar := &PersistentJar{PSL: testPSL{}, Now: func() time.Time { return now }}
+
+	u, _ := url.Parse("http://www.example.co.uk/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "public", Value: "1", Domain: "co.uk"},
+		{Name: "other", Value: "1", Domain: "other.co.uk"},
+		{Name: "secure", Value: "1", Secure: true},
+		{Name: "domain", Value: "1", Domain: ".example.co.uk", Path: "/"},
+		{Name: "host", Value: "1", Expires: now.Add(time.Minute)},
+	})
+
+	names := func(cookies []*http.Cookie) (s []string) {
+		for _, c := range cookies {
+			s = append(s, c.Name)
+		}
+
+		return s
+	}
+
+	assert.Equal(t, []string{"host", "domain"}, names(jar.Cookies(u)))
+
+	sub, _ := url.Parse("https://api.example.co.uk/a")
+	assert.Equal(t, []string{"domain"}, names(jar.Cookies(sub)))
+
+	jar.SetCookies(sub, []*http.Cookie{{Name: "secure", Value: "2", Secure: true}})
+	assert.Equal(t, []string{"domain", "secure"}, names(jar.Cookies(sub)))
+
+	plain, _ := url.Parse("http://api.example.co.uk/a")
+	assert.Equal(t, []string{"domain"}, names(jar.Cookies(plain)))
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, []string{"domain"}, names(jar.Cookies(u)))
+
+	var buf bytes.Buffer
+
+	assert.Nil(t, jar.Export(&buf, "example.co.uk"))
+	assert.Equal(t, "# Netscape HTTP Cookie File\n"+
+		".example.co.uk\tTRUE\t/\tFALSE\t0\tdomain\t1\n"+
+		"api.example.co.uk\tFALSE\t/\tTRUE\t0\tsecure\t2\n", buf.String())
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "domain", Domain: "example.co.uk", Path: "/", MaxAge: -1}})
+	assert.Len(t, jar.List("example.co.uk"), 1)
+}
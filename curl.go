@@ -0,0 +1,359 @@
+package gonet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CurlOptions are the options of the curl command besides the request.
+type CurlOptions struct {
+	// KeyFile, CertFile and CACert are the TLS client key, client cert and server root CA files,
+	// rendered as --key, --cert and --cacert.
+	KeyFile, CertFile, CACert string
+	// Insecure renders -k to skip the TLS verification.
+	Insecure bool
+	// Protocol renders --http2 or --http2-prior-knowledge.
+	Protocol Protocol
+	// Jar is the cookie jar whose cookies of the url are sent by -b.
+	Jar http.CookieJar
+}
+
+// curlPart is a part of the multipart body.
+type curlPart struct {
+	name, value, filename, contentType string
+}
+
+// CurlCommand renders the request as a shell-escaped curl command.
+// The body is read by GetBody if possible, otherwise it is read and restored, so the request can still be sent.
+// The multipart bodies are rendered as the -F parts, and the file parts as @filename.
+func CurlCommand(req *http.Request, opts *CurlOptions) (string, error) {
+	return curlCommand(req, opts, nil)
+}
+
+// Curl renders the request as a shell-escaped curl command, with the params, the files,
+// the cookies of the jar and the static authenticators (BasicAuth and BearerAuth) applied like sending.
+// The body compressed by the Compress is rendered compressed with its Content-Encoding,
+// except the multipart files which are rendered as the -F parts uncompressed.
+// It does not send the request.
+func (b *HTTPReq) Curl() (string, error) {
+	r := b.req.Clone(b.req.Context())
+
+	var parts []curlPart
+
+	if b.resp.StatusCode == 0 { // not sent yet, apply the params, the files and the compression like SendOut
+		var err error
+
+		if parts, err = b.curlPrepare(r); err != nil {
+			return "", err
+		}
+
+		if b.setting.Compress != "" && parts == nil {
+			if err := CompressRequest(r, b.setting.Compress, b.setting.CompressMinSize); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if b.setting.UserAgent != "" && r.Header.Get("User-Agent") == "" {
+		r.Header.Set("User-Agent", b.setting.UserAgent)
+	}
+
+	switch a := b.setting.Auth.(type) {
+	case BasicAuth:
+		r.SetBasicAuth(a.Username, a.Password)
+	case BearerAuth:
+		r.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+
+	opts := &CurlOptions{Protocol: b.setting.Protocol}
+	switch {
+	case !b.setting.EnableCookie:
+	case b.setting.Jar != nil:
+		opts.Jar = b.setting.Jar
+	case b.setting.CookieJar != nil:
+		opts.Jar = b.setting.CookieJar
+	}
+
+	if c := b.setting.TLSClientConfig; c != nil {
+		opts.Insecure = c.InsecureSkipVerify
+	}
+
+	return curlCommand(r, opts, parts)
+}
+
+func (b *HTTPReq) curlPrepare(r *http.Request) ([]curlPart, error) {
+	u, err := url.Parse(b.url)
+	if err != nil {
+		return nil, err
+	}
+
+	r.URL = u
+	paramBody := b.params.Encode()
+
+	switch m := r.Method; {
+	case m == "GET" && paramBody != "":
+		if u.RawQuery != "" {
+			u.RawQuery += "&" + paramBody
+		} else {
+			u.RawQuery = paramBody
+		}
+	case (m == "POST" || m == "PUT" || m == "PATCH") && r.Body == nil && len(b.files) > 0:
+		parts := make([]curlPart, 0, len(b.files)+len(b.params))
+
+		// the same order as writeMultipart
+		for _, f := range b.files {
+			parts = append(parts, curlPart{name: f.FormName, filename: f.Filename, contentType: f.ContentType})
+		}
+
+		keys := make([]string, 0, len(b.params))
+		for k := range b.params {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			for _, v := range b.params[k] {
+				parts = append(parts, curlPart{name: k, value: v})
+			}
+		}
+
+		return parts, nil
+	case (m == "POST" || m == "PUT" || m == "PATCH") && r.Body == nil && paramBody != "":
+		data, contentType, err := EncodeBody(MediaTypeForm, b.params)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	return nil, nil
+}
+
+func curlCommand(req *http.Request, opts *CurlOptions, parts []curlPart) (string, error) {
+	if opts == nil {
+		opts = &CurlOptions{}
+	}
+
+	body, err := curlBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if parts == nil && mediaType == "multipart/form-data" && body != nil && req.Header.Get("Content-Encoding") == "" {
+		if parts, err = parseCurlParts(body, params["boundary"]); err != nil {
+			return "", err
+		}
+	}
+
+	args := []string{"curl"}
+
+	hasBody := len(body) > 0 || len(parts) > 0
+
+	switch {
+	case req.Method == http.MethodHead:
+		args = append(args, "--head")
+	case req.Method == "" || req.Method == http.MethodGet && !hasBody || req.Method == http.MethodPost && hasBody:
+	default:
+		args = append(args, "-X", req.Method)
+	}
+
+	args = append(args, shellQuote(req.URL.String()))
+	args = append(args, curlHeaderArgs(req, len(parts) > 0)...)
+
+	cookies := req.Header.Values("Cookie")
+	if opts.Jar != nil {
+		for _, c := range opts.Jar.Cookies(req.URL) {
+			cookies = append(cookies, c.String())
+		}
+	}
+
+	if len(cookies) > 0 {
+		args = append(args, "-b", shellQuote(strings.Join(cookies, "; ")))
+	}
+
+	for _, p := range parts {
+		if p.filename == "" {
+			args = append(args, "--form-string", shellQuote(p.name+"="+p.value))
+			continue
+		}
+
+		part := p.name + "=@" + p.filename
+		if p.contentType != "" {
+			part += ";type=" + p.contentType
+		}
+
+		args = append(args, "-F", shellQuote(part))
+	}
+
+	if len(parts) == 0 && len(body) > 0 {
+		args = append(args, "--data-binary", shellQuoteBytes(body))
+	}
+
+	args = append(args, curlTLSArgs(req, opts)...)
+
+	return strings.Join(args, " "), nil
+}
+
+func curlHeaderArgs(req *http.Request, multipart bool) []string {
+	var args []string
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch {
+		case k == "Content-Length" || k == "Cookie":
+			continue
+		case k == "Content-Type" && multipart: // curl makes its own boundary
+			continue
+		}
+
+		for _, v := range req.Header[k] {
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	return args
+}
+
+func curlTLSArgs(req *http.Request, opts *CurlOptions) []string {
+	var args []string
+
+	switch opts.Protocol {
+	case ProtocolHTTP2:
+		args = append(args, "--http2")
+	case ProtocolH2C:
+		if req.URL.Scheme == "http" {
+			args = append(args, "--http2-prior-knowledge")
+		} else {
+			args = append(args, "--http2")
+		}
+	}
+
+	if req.URL.Scheme != "https" {
+		return args
+	}
+
+	if opts.Insecure {
+		args = append(args, "-k")
+	}
+
+	if opts.CertFile != "" {
+		args = append(args, "--cert", shellQuote(opts.CertFile))
+	}
+
+	if opts.KeyFile != "" {
+		args = append(args, "--key", shellQuote(opts.KeyFile))
+	}
+
+	if opts.CACert != "" {
+		args = append(args, "--cacert", shellQuote(opts.CACert))
+	}
+
+	return args
+}
+
+// curlBody reads the request body by GetBody, or reads and restores the body.
+func curlBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		defer body.Close()
+
+		return ioutil.ReadAll(body)
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	return data, err
+}
+
+func parseCurlParts(body []byte, boundary string) ([]curlPart, error) {
+	var parts []curlPart
+
+	r := multipart.NewReader(bytes.NewReader(body), boundary)
+
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("parse multipart body: %w", err)
+		}
+
+		if filename := p.FileName(); filename != "" {
+			parts = append(parts, curlPart{name: p.FormName(), filename: filename, contentType: p.Header.Get("Content-Type")})
+			continue
+		}
+
+		value, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, curlPart{name: p.FormName(), value: string(value)})
+	}
+}
+
+// shellQuote quotes the string for the POSIX shells by the single quotes if required.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.,/:@%+=") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellQuoteBytes quotes the text like shellQuote, and the binary data by the $'\xHH' quoting of bash and zsh.
+func shellQuoteBytes(data []byte) string {
+	if utf8.Valid(data) && bytes.IndexByte(data, 0) < 0 {
+		return shellQuote(string(data))
+	}
+
+	var b strings.Builder
+
+	b.WriteString("$'")
+
+	for _, c := range data {
+		if c >= 0x20 && c < 0x7f && c != '\'' && c != '\\' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+
+	b.WriteString("'")
+
+	return b.String()
+}
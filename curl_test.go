@@ -0,0 +1,83 @@
+package gonet
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurl(t *testing.T) {
+	req := MustGet("http://127.0.0.1:8080/api?x=1").Param("name", "bingoo huang").
+		Header("X-Quote", "it's").Cookie(&http.Cookie{Name: "sid", Value: "s1"})
+	req.setting.UserAgent = ""
+
+	cmd, err := req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, `curl 'http://127.0.0.1:8080/api?x=1&name=bingoo+huang' -H 'X-Quote: it'\''s' -b sid=s1`, cmd)
+
+	req = MustPost("https://127.0.0.1/api").Auth(BearerAuth{Token: "t0k3n"}).
+		TLSClientConfig(&tls.Config{InsecureSkipVerify: true}) // nolint gosec
+	req.setting.UserAgent = ""
+	assert.Nil(t, req.JSONBody(map[string]string{"name": "bingoo"}))
+
+	cmd, err = req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, `curl https://127.0.0.1/api -H 'Authorization: Bearer t0k3n'`+
+		` -H 'Content-Type: application/json;charset=utf-8' --data-binary '{"name":"bingoo"}' -k`, cmd)
+
+	req = MustPut("http://127.0.0.1/upload").Param("k", "v").PostFile("file", "/tmp/a b.txt")
+	req.setting.UserAgent = ""
+
+	cmd, err = req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X PUT http://127.0.0.1/upload -F 'file=@/tmp/a b.txt' --form-string k=v`, cmd)
+
+	req = MustPatch("http://127.0.0.1/form").Param("k", "v").Protocol(ProtocolH2C)
+	req.setting.UserAgent = ""
+
+	cmd, err = req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X PATCH http://127.0.0.1/form -H 'Content-Type: application/x-www-form-urlencoded'`+
+		` --data-binary k=v --http2-prior-knowledge`, cmd)
+
+	req = MustPost("http://127.0.0.1/bin")
+	req.setting.UserAgent = ""
+	req.Body([]byte{0, 'a', '\''})
+
+	cmd, err = req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, `curl http://127.0.0.1/bin --data-binary $'\x00a\x27'`, cmd)
+
+	// the compressed body is rendered like sent
+	req = MustPost("http://127.0.0.1/gzip").Compress("gzip")
+	req.setting.UserAgent = ""
+	req.Body("bingoohuang")
+
+	cmd, err = req.Curl()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(cmd,
+		`curl http://127.0.0.1/gzip -H 'Content-Encoding: gzip' --data-binary $'\x1f\x8b`), cmd)
+}
+
+func TestCurlAfterSending(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	req := MustPost(ts.URL).Param("k", "v").PostFileReader("file", "a.txt", "text/plain", strings.NewReader("hello"))
+	req.setting.UserAgent = ""
+
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", str)
+
+	// the multipart body built is parsed back to the parts.
+	cmd, err := req.Curl()
+	assert.Nil(t, err)
+	assert.Equal(t, "curl "+ts.URL+" -F 'file=@a.txt;type=text/plain' --form-string k=v", cmd)
+}
@@ -4,54 +4,78 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"golang.org/x/net/proxy"
 )
 
-// DialerTimeoutBean ...
+// DialerTimeoutBean is the dialer with the connecting timeout, the idle read/write timeout
+// and the total lifetime of the dialed connections.
 type DialerTimeoutBean struct {
+	// ReadWriteTimeout is the idle timeout of every read or write operation,
+	// the deadline slides forward on each activity in the connection.
 	ReadWriteTimeout time.Duration
-	ConnTimeout      time.Duration
+	// ConnTimeout is the timeout of dialing.
+	ConnTimeout time.Duration
+	// Lifetime is the total lifetime of the connection since dialed, zero for no limit.
+	Lifetime time.Duration
+	// Proxy is the proxy config whose SOCKS5 proxy is dialed through, nil to dial directly.
+	Proxy *ProxyConfig
 }
 
 var _ proxy.Dialer = (*DialerTimeoutBean)(nil)
 
-// DialContext ...
+// DialContext dials with the ctx.
 func (d DialerTimeoutBean) DialContext(ctx context.Context, network, addr string) (c net.Conn, err error) {
 	dialer := &net.Dialer{Timeout: d.ConnTimeout}
-	c, err = dialer.DialContext(ctx, network, addr)
 
-	if err != nil {
-		return nil, err
+	if d.Proxy != nil {
+		c, err = d.Proxy.DialContext(ctx, network, addr, dialer.DialContext)
+	} else {
+		c, err = dialer.DialContext(ctx, network, addr)
 	}
 
-	if d.ReadWriteTimeout > 0 {
-		c = &tcpConn{TCPConn: c.(*net.TCPConn), timeout: d.ReadWriteTimeout}
+	if err != nil {
+		return nil, err
 	}
 
-	return c, nil
+	return d.wrap(c), nil
 }
 
-// Dial ...
+// Dial dials without any ctx.
 func (d DialerTimeoutBean) Dial(network, addr string) (c net.Conn, err error) {
-	dialer := &net.Dialer{Timeout: d.ConnTimeout}
-	c, err = dialer.Dial(network, addr)
+	return d.DialContext(context.Background(), network, addr)
+}
 
-	if err != nil {
-		return nil, err
+func (d DialerTimeoutBean) wrap(c net.Conn) net.Conn {
+	if d.ReadWriteTimeout <= 0 && d.Lifetime <= 0 {
+		return c
 	}
 
-	if d.ReadWriteTimeout > 0 {
-		c = &tcpConn{TCPConn: c.(*net.TCPConn), timeout: d.ReadWriteTimeout}
+	tc := &timeoutConn{Conn: c, idle: d.ReadWriteTimeout}
+	if d.Lifetime > 0 {
+		tc.expires = time.Now().Add(d.Lifetime)
 	}
 
-	return c, nil
+	return tc
 }
 
 // DialContextFn was defined to make code more readable.
 type DialContextFn func(ctx context.Context, network, address string) (net.Conn, error)
 
+var _ proxy.ContextDialer = DialContextFn(nil)
+
+// DialContext dials with the ctx.
+func (f DialContextFn) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// Dial dials without any ctx.
+func (f DialContextFn) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
 // DialerTimeout  implements our own dialer in order to set read and write idle timeouts.
 func DialerTimeout(rwtimeout, ctimeout time.Duration) func(network, addr string) (c net.Conn, err error) {
 	return DialerTimeoutBean{ReadWriteTimeout: rwtimeout, ConnTimeout: ctimeout}.Dial
@@ -62,27 +86,96 @@ func DialContextTimeout(rwtimeout, ctimeout time.Duration) func(ctx context.Cont
 	return DialerTimeoutBean{ReadWriteTimeout: rwtimeout, ConnTimeout: ctimeout}.DialContext
 }
 
-// tcpConn is our own net.Conn which sets a read and write deadline and resets them each
-// time there is read or write activity in the connection.
-type tcpConn struct {
-	*net.TCPConn
-	timeout time.Duration
+// Dialer defines dialer function alias
+type Dialer func(ctx context.Context, net, addr string) (c net.Conn, err error)
+
+// TimeoutDialer returns functions of connection dialer with timeout settings for http.Transport Dial field.
+// The rwTimeout is an idle timeout which is reset on every read or write activity.
+// https://gist.github.com/c4milo/275abc6eccbfd88ad56ca7c77947883a
+// HTTP client with support for read and write timeouts which are missing in Go's standard library.
+func TimeoutDialer(cTimeout time.Duration, rwTimeout time.Duration) Dialer {
+	return DialerTimeoutBean{ReadWriteTimeout: rwTimeout, ConnTimeout: cTimeout}.DialContext
 }
 
-func (c *tcpConn) Read(b []byte) (int, error) {
-	if err := c.TCPConn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+// timeoutConn is our own net.Conn which sets a read and write deadline and resets them each
+// time there is read or write activity in the connection, but never beyond its lifetime.
+// It works for any net.Conn, and for the TLS connections layered on top of it.
+type timeoutConn struct {
+	net.Conn
+	idle    time.Duration
+	expires time.Time
+
+	lock sync.Mutex
+	// readDeadline and writeDeadline are the deadlines set explicitly by the users of the connection.
+	readDeadline, writeDeadline time.Time
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.lock.Lock()
+	d := c.deadline(c.readDeadline)
+	c.lock.Unlock()
+
+	if err := c.Conn.SetReadDeadline(d); err != nil {
 		return 0, err
 	}
 
-	return c.TCPConn.Read(b)
+	return c.Conn.Read(b)
 }
 
-func (c *tcpConn) Write(b []byte) (int, error) {
-	if err := c.TCPConn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	d := c.deadline(c.writeDeadline)
+	c.lock.Unlock()
+
+	if err := c.Conn.SetWriteDeadline(d); err != nil {
 		return 0, err
 	}
 
-	return c.TCPConn.Write(b)
+	return c.Conn.Write(b)
+}
+
+// deadline returns the earliest one among the idle deadline, the lifetime and the explicit deadline.
+func (c *timeoutConn) deadline(explicit time.Time) time.Time {
+	d := explicit
+
+	if c.idle > 0 {
+		d = earlier(d, time.Now().Add(c.idle))
+	}
+
+	return earlier(d, c.expires)
+}
+
+func (c *timeoutConn) SetDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.lock.Unlock()
+
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *timeoutConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.readDeadline = t
+	c.lock.Unlock()
+
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *timeoutConn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.writeDeadline = t
+	c.lock.Unlock()
+
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// earlier returns the earlier time of a and b, the zero time means no limit.
+func earlier(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+
+	return a
 }
 
 // DefaultClient returns a default client with sensible values for slow 3G connections and above.
@@ -0,0 +1,123 @@
+package gonet
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowWriter writes the chunks with the gap among them.
+func slowWriter(t *testing.T, network, addr string, chunks int, gap time.Duration) net.Listener {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+
+				for i := 0; i < chunks; i++ {
+					time.Sleep(gap)
+
+					if _, err := c.Write([]byte("x")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return l
+}
+
+func TestDialerIdleTimeout(t *testing.T) {
+	l := slowWriter(t, "tcp", "127.0.0.1:0", 5, 50*time.Millisecond) // nolint gomnd
+	defer l.Close()
+
+	d := DialerTimeoutBean{ConnTimeout: time.Second, ReadWriteTimeout: 200 * time.Millisecond} // nolint gomnd
+	c, err := d.DialContext(context.Background(), "tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	defer c.Close()
+
+	// total 250ms is longer than the idle timeout 200ms, but the data keeps flowing.
+	data, err := ioutil.ReadAll(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "xxxxx", string(data))
+}
+
+func TestDialerLifetime(t *testing.T) {
+	l := slowWriter(t, "tcp", "127.0.0.1:0", 5, 50*time.Millisecond) // nolint gomnd
+	defer l.Close()
+
+	d := DialerTimeoutBean{
+		ConnTimeout:      time.Second,
+		ReadWriteTimeout: 200 * time.Millisecond, // nolint gomnd
+		Lifetime:         120 * time.Millisecond, // nolint gomnd
+	}
+	c, err := d.DialContext(context.Background(), "tcp", l.Addr().String())
+	assert.Nil(t, err)
+
+	defer c.Close()
+
+	_, err = ioutil.ReadAll(c)
+	assert.True(t, IsTimeoutError(err))
+}
+
+func TestDialerUnix(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gonet.sock")
+	l := slowWriter(t, "unix", sock, 3, 10*time.Millisecond) // nolint gomnd
+
+	defer l.Close()
+
+	c, err := DialerTimeout(time.Second, time.Second)("unix", sock)
+	assert.Nil(t, err)
+
+	defer c.Close()
+
+	data, err := ioutil.ReadAll(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "xxx", string(data))
+}
+
+func TestDialerTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			time.Sleep(50 * time.Millisecond) // nolint gomnd
+
+			_, _ = w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	option := NewReqOption()
+	option.Pool = NewTransportPool()
+	option.ReadWriteTimeout = 200 * time.Millisecond               // nolint gomnd
+	option.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // nolint gosec
+
+	defer option.Close()
+
+	s, err := option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "xxxxx", s)
+
+	s, err = option.MustGet(ts.URL).ConnLifetime(120 * time.Millisecond).String() // nolint gomnd
+	assert.True(t, IsTimeoutError(err), "%v", err)
+	assert.Empty(t, s)
+}
@@ -0,0 +1,368 @@
+package gonet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Downloader downloads the file by the concurrent range requests over the pooled transport.
+type Downloader struct {
+	// Option is the option of the requests, NewReqOption() will be used if nil.
+	Option *ReqOption
+	// Segments is the number of the concurrent segments, 4 by default.
+	Segments int
+	// MinSegmentSize is the minimum size of the segments, 1MiB by default.
+	MinSegmentSize int64
+	// Retries is the maximum number of the retries of every failed segment, 3 by default.
+	Retries int
+	// RetryWait is the time to wait before retrying the failed segment, 1s by default.
+	RetryWait time.Duration
+}
+
+// DownloadStats is the statistics of the downloading.
+type DownloadStats struct {
+	// Size is the size of the file, -1 for unknown.
+	Size int64
+	// RangeSupported tells the server supports the range requests.
+	RangeSupported bool
+	// Segments is the number of the segments downloaded.
+	Segments int
+	// Retries is the total number of the retries of the failed segments.
+	Retries int
+	// Bytes is the total number of the bytes received, including the ones of the failed attempts.
+	Bytes int64
+	// Elapsed is the time cost of the downloading.
+	Elapsed time.Duration
+}
+
+// Download downloads the url to the file by the default Downloader.
+func Download(ctx context.Context, url, filename string) (*DownloadStats, error) {
+	return (&Downloader{}).Download(ctx, url, filename)
+}
+
+// Download downloads the url to the file. It probes the size and the range support by a HEAD request,
+// or a ranged GET request when the HEAD is not allowed, then fetches the segments concurrently
+// into the preallocated file filename + PartSuffix, which is renamed to filename at last.
+// It falls back to a single GET when the ranges are not supported.
+func (d *Downloader) Download(ctx context.Context, url, filename string) (*DownloadStats, error) {
+	start := time.Now()
+	stats := &DownloadStats{Size: -1}
+
+	size, etag, whole, err := d.probe(ctx, url)
+	if err != nil {
+		return stats, err
+	}
+
+	part := filename + PartSuffix
+
+	if size > 0 {
+		stats.Size, stats.RangeSupported = size, true
+		err = d.downloadSegments(ctx, url, etag, part, stats)
+	} else {
+		stats.Segments = 1
+		err = d.downloadWhole(ctx, url, part, whole, stats)
+	}
+
+	stats.Elapsed = time.Since(start)
+
+	if err != nil {
+		_ = os.Remove(part)
+		return stats, err
+	}
+
+	return stats, os.Rename(part, filename)
+}
+
+func (d *Downloader) option() *ReqOption {
+	if d.Option != nil {
+		return d.Option
+	}
+
+	return NewReqOption()
+}
+
+func (d *Downloader) segments() int {
+	if d.Segments > 0 {
+		return d.Segments
+	}
+
+	return 4 // nolint gomnd
+}
+
+func (d *Downloader) minSegmentSize() int64 {
+	if d.MinSegmentSize > 0 {
+		return d.MinSegmentSize
+	}
+
+	return 1 << 20 // nolint gomnd
+}
+
+func (d *Downloader) retries() int {
+	if d.Retries > 0 {
+		return d.Retries
+	}
+
+	return 3 // nolint gomnd
+}
+
+func (d *Downloader) retryWait() time.Duration {
+	if d.RetryWait > 0 {
+		return d.RetryWait
+	}
+
+	return 1 * time.Second
+}
+
+// probe probes the size and the strong ETag of the url, the size is 0 when the ranges are not supported.
+// The whole response is returned when the server ignores the range of the probing GET,
+// whose body is the whole file to read.
+func (d *Downloader) probe(ctx context.Context, url string) (size int64, etag string, whole *http.Response, err error) {
+	req, err := d.option().HeadCtx(ctx, url)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	resp, err := req.Response()
+	closeBody(resp)
+
+	if err == nil && resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0 {
+		return resp.ContentLength, strongETag(resp), nil, nil
+	}
+
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusMethodNotAllowed) {
+		return 0, "", nil, err
+	}
+
+	// the HEAD is not allowed or tells nothing, try the ranged GET.
+	if req, err = d.option().GetCtx(ctx, url); err != nil {
+		return 0, "", nil, err
+	}
+
+	req.Header("Range", "bytes=0-0").Header("Accept-Encoding", "identity")
+
+	resp, err = req.Response()
+	if err != nil {
+		closeBody(resp)
+		return 0, "", nil, err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, "", resp, nil
+	}
+
+	closeBody(resp)
+
+	if total := rangeTotal(resp.Header.Get("Content-Range")); total > 0 {
+		return total, strongETag(resp), nil, nil
+	}
+
+	return 0, "", nil, nil
+}
+
+// strongETag returns the ETag of the response if it is strong.
+func strongETag(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return ""
+}
+
+// downloadWhole downloads the url by a single GET, or reads the whole response of the probing if not nil.
+func (d *Downloader) downloadWhole(ctx context.Context, url, part string, resp *http.Response,
+	stats *DownloadStats) error {
+	if resp == nil {
+		req, err := d.option().GetCtx(ctx, url)
+		if err != nil {
+			return err
+		}
+
+		if resp, err = req.Response(); err != nil {
+			closeBody(resp)
+			return err
+		}
+	}
+
+	defer resp.Body.Close()
+
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	stats.Bytes, stats.Size = n, n
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// segment is a range [Start, End] of the file.
+type segment struct {
+	Start, End int64
+}
+
+// splitSegments splits the size into n segments, not smaller than minSize except the last one.
+func splitSegments(size int64, n int, minSize int64) []segment {
+	segSize := (size + int64(n) - 1) / int64(n)
+	if segSize < minSize {
+		segSize = minSize
+	}
+
+	segments := make([]segment, 0, n)
+
+	for start := int64(0); start < size; start += segSize {
+		end := start + segSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		segments = append(segments, segment{Start: start, End: end})
+	}
+
+	return segments
+}
+
+// downloadSegments downloads the segments concurrently into the preallocated part file.
+func (d *Downloader) downloadSegments(ctx context.Context, url, etag, part string, stats *DownloadStats) error {
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(stats.Size); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := splitSegments(stats.Size, d.segments(), d.minSegmentSize())
+	stats.Segments = len(segments)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		retries  int32
+	)
+
+	for _, seg := range segments {
+		wg.Add(1)
+
+		go func(seg segment) {
+			defer wg.Done()
+
+			n, r, err := d.downloadSegment(ctx, url, etag, f, seg)
+			atomic.AddInt64(&stats.Bytes, n)
+			atomic.AddInt32(&retries, int32(r))
+
+			if err != nil {
+				errOnce.Do(func() { firstErr = err; cancel() })
+			}
+		}(seg)
+	}
+
+	wg.Wait()
+
+	stats.Retries = int(retries)
+
+	if err := f.Close(); firstErr == nil {
+		firstErr = err
+	}
+
+	return firstErr
+}
+
+// downloadSegment downloads the segment, and retries from the last written offset on failures.
+// It returns the number of bytes received and the number of retries.
+func (d *Downloader) downloadSegment(ctx context.Context, url, etag string, f io.WriterAt,
+	seg segment) (received int64, retries int, err error) {
+	w := &offsetWriter{w: f, off: seg.Start}
+
+	for ; ; retries++ {
+		if retries > 0 {
+			timer := time.NewTimer(d.retryWait())
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return received, retries, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		start := w.off
+		err = d.fetchRange(ctx, url, etag, w, segment{Start: start, End: seg.End})
+		received += w.off - start
+
+		if err == nil || ctx.Err() != nil || retries >= d.retries() {
+			return received, retries, err
+		}
+	}
+}
+
+// fetchRange fetches the range of the url to the writer.
+func (d *Downloader) fetchRange(ctx context.Context, url, etag string, w io.Writer, seg segment) error {
+	req, err := d.option().GetCtx(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	req.Header("Range", fmt.Sprintf("bytes=%d-%d", seg.Start, seg.End)).Header("Accept-Encoding", "identity")
+
+	if etag != "" {
+		req.Header("If-Range", etag)
+	}
+
+	resp, err := req.Response()
+	if err != nil {
+		closeBody(resp)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("unexpected status %s for the range %d-%d, the file may be changed",
+			resp.Status, seg.Start, seg.End)
+	}
+
+	if start := rangeStart(resp.Header.Get("Content-Range")); start != seg.Start {
+		return fmt.Errorf("unexpected Content-Range %q for the range %d-%d",
+			resp.Header.Get("Content-Range"), seg.Start, seg.End)
+	}
+
+	size := seg.End - seg.Start + 1
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, size))
+	if err == nil && n < size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// offsetWriter writes to the io.WriterAt at the increasing offset.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,105 @@
+package gonet
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte(fileContent), 100)
+
+	var (
+		lock   sync.Mutex
+		ranges = map[string]int{}
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && r.URL.Path == "/nohead" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		lock.Lock()
+		ranges[r.Header.Get("Range")]++
+		times := ranges[r.Header.Get("Range")]
+		lock.Unlock()
+
+		if r.Header.Get("Range") == "bytes=1000-1999" && times == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // the first attempt of the segment fails
+			return
+		}
+
+		if r.URL.Path == "/norange" {
+			_, _ = w.Write(content)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d := &Downloader{Segments: 4, MinSegmentSize: 1000, RetryWait: time.Millisecond}
+
+	for _, path := range []string{"/", "/nohead"} {
+		f := filepath.Join(dir, "file.txt")
+		stats, err := d.Download(context.Background(), ts.URL+path, f)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(content)), stats.Size)
+		assert.True(t, stats.RangeSupported)
+		assert.Equal(t, 4, stats.Segments)
+		assert.Equal(t, int64(len(content)), stats.Bytes)
+
+		data, _ := ioutil.ReadFile(f)
+		assert.Equal(t, content, data)
+	}
+
+	assert.Equal(t, 1, ranges["bytes=0-0"])
+	assert.Equal(t, 3, ranges["bytes=1000-1999"]) // failed once, then retried, and downloaded again
+
+	f := filepath.Join(dir, "norange.txt")
+	stats, err := d.Download(context.Background(), ts.URL+"/norange", f)
+	assert.Nil(t, err)
+	assert.False(t, stats.RangeSupported)
+	assert.Equal(t, 1, stats.Segments)
+
+	assert.Equal(t, int64(len(content)), stats.Bytes)
+	assert.Equal(t, 2, ranges[""]) // the HEADs of / and /norange, the ignored probing range is read as the file
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, content, data)
+}
+
+func TestDownloadRetriesExhausted(t *testing.T) {
+	content := bytes.Repeat([]byte(fileContent), 100)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=2700-3599" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		http.ServeContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	d := &Downloader{Segments: 4, MinSegmentSize: 1, Retries: 2, RetryWait: time.Millisecond}
+
+	stats, err := d.Download(context.Background(), ts.URL, filepath.Join(dir, "file.txt"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, stats.Retries)
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
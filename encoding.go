@@ -0,0 +1,325 @@
+package gonet
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentDecoder creates the reader which decodes the body of a content coding, like gzip.
+type ContentDecoder func(r io.Reader) (io.ReadCloser, error)
+
+// ContentEncoder creates the writer which encodes the body by a content coding, the writer is closed to flush at last.
+type ContentEncoder func(w io.Writer) (io.WriteCloser, error)
+
+// nolint gochecknoglobals
+var (
+	contentDecoders = map[string]ContentDecoder{
+		"gzip":    decodeGzip,
+		"x-gzip":  decodeGzip,
+		"deflate": decodeDeflate,
+		"br":      decodeBrotli,
+		"zstd":    decodeZstd,
+	}
+	contentEncoders = map[string]ContentEncoder{
+		"gzip":    func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		"deflate": func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+		"br":      func(w io.Writer) (io.WriteCloser, error) { return brotli.NewWriter(w), nil },
+		"zstd":    func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	}
+	// acceptEncodings are the codings in the Accept-Encoding, the registered ones appended in order.
+	acceptEncodings = []string{"gzip", "deflate", "br", "zstd"}
+	encodingsLock   sync.RWMutex
+)
+
+// RegisterContentDecoder registers the decoder of the content coding, like lz4, replacing the existing one.
+// The coding is accepted in the Accept-Encoding of the requests since then.
+func RegisterContentDecoder(coding string, decoder ContentDecoder) {
+	coding = strings.ToLower(coding)
+
+	encodingsLock.Lock()
+	defer encodingsLock.Unlock()
+
+	if _, ok := contentDecoders[coding]; !ok {
+		acceptEncodings = append(acceptEncodings, coding)
+	}
+
+	contentDecoders[coding] = decoder
+}
+
+// RegisterContentEncoder registers the encoder of the content coding to compress the request bodies,
+// replacing the existing one.
+func RegisterContentEncoder(coding string, encoder ContentEncoder) {
+	encodingsLock.Lock()
+	defer encodingsLock.Unlock()
+
+	contentEncoders[strings.ToLower(coding)] = encoder
+}
+
+// LookupContentDecoder looks up the decoder of the content coding.
+func LookupContentDecoder(coding string) (ContentDecoder, bool) {
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+
+	d, ok := contentDecoders[strings.ToLower(coding)]
+
+	return d, ok
+}
+
+// LookupContentEncoder looks up the encoder of the content coding.
+func LookupContentEncoder(coding string) (ContentEncoder, bool) {
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+
+	e, ok := contentEncoders[strings.ToLower(coding)]
+
+	return e, ok
+}
+
+// AcceptEncoding returns the Accept-Encoding of the registered decoders, like gzip, deflate, br, zstd.
+func AcceptEncoding() string {
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+
+	return strings.Join(acceptEncodings, ", ")
+}
+
+// DecodeContent replaces the body of the response by the decoded one of its Content-Encoding,
+// and removes the Content-Encoding and the Content-Length, like the transparent gzip decoding of http.Transport.
+// The response is untouched if any of its codings has no decoder registered.
+// The decoders are created at the first reading, and their errors are returned by the reading.
+func DecodeContent(resp *http.Response) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	codings := contentCodings(resp.Header.Get("Content-Encoding"))
+	if len(codings) == 0 {
+		return
+	}
+
+	for _, c := range codings {
+		if _, ok := LookupContentDecoder(c); !ok {
+			return
+		}
+	}
+
+	resp.Body = &decodedBody{body: resp.Body, codings: codings}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// acceptEncoding sends the request with the Accept-Encoding of the registered decoders if it has none,
+// on a copy of the request to keep it implicit like the gzip one of http.Transport.
+// Like http.Transport, the HEAD requests and the ranged ones are left alone,
+// for their Content-Length and byte ranges are of the identity body.
+func acceptEncoding(next Invoker) Invoker {
+	return func(req *http.Request) (*http.Response, error) {
+		if !NegotiateEncoding(req) {
+			return next(req)
+		}
+
+		r := req.Clone(req.Context())
+		r.Header.Set("Accept-Encoding", AcceptEncoding())
+
+		return next(r)
+	}
+}
+
+// NegotiateEncoding tells whether the Accept-Encoding of the registered decoders should be sent with the request,
+// false if it has one already, or it is a HEAD request or a ranged one.
+func NegotiateEncoding(req *http.Request) bool {
+	return req.Method != http.MethodHead && req.Header.Get("Range") == "" && req.Header.Get("Accept-Encoding") == ""
+}
+
+// contentCodings parses the codings of the Content-Encoding in the applied order, the identity is ignored.
+func contentCodings(contentEncoding string) []string {
+	var codings []string
+
+	for _, c := range strings.Split(contentEncoding, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+			codings = append(codings, c)
+		}
+	}
+
+	return codings
+}
+
+// Compress compresses the request body by the content coding, like gzip or zstd, see ReqOption.Compress.
+func (b *HTTPReq) Compress(coding string) *HTTPReq {
+	b.setting.Compress = coding
+
+	return b
+}
+
+// CompressRequest compresses the body of the request by the content coding, and sets the Content-Encoding.
+// The body is compressed on the fly with the unknown Content-Length, and the GetBody, if any, is kept replayable
+// by compressing the bodies it returns likewise. The body with a known Content-Length smaller than minSize
+// is left as it is. The request already with a Content-Encoding is untouched.
+func CompressRequest(req *http.Request, coding string, minSize int) error {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	encoder, ok := LookupContentEncoder(coding)
+	if !ok {
+		return fmt.Errorf("no content encoder registered for %s", coding)
+	}
+
+	if req.ContentLength > 0 && req.ContentLength < int64(minSize) {
+		return nil
+	}
+
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			return &compressedBody{body: body, encoder: encoder}, nil
+		}
+	}
+
+	req.Body = &compressedBody{body: req.Body, encoder: encoder}
+	req.ContentLength = -1
+	req.Header.Set("Content-Encoding", coding)
+
+	return nil
+}
+
+// compressedBody compresses the body through a pipe, by the goroutine started at the first reading.
+type compressedBody struct {
+	body    io.ReadCloser
+	encoder ContentEncoder
+	once    sync.Once
+	pr      *io.PipeReader
+}
+
+func (c *compressedBody) Read(p []byte) (int, error) {
+	c.once.Do(c.start)
+
+	return c.pr.Read(p)
+}
+
+func (c *compressedBody) Close() error {
+	c.once.Do(func() { // closed without reading
+		var pw *io.PipeWriter
+
+		c.pr, pw = io.Pipe()
+		_ = pw.CloseWithError(c.body.Close())
+	})
+
+	return c.pr.Close()
+}
+
+func (c *compressedBody) start() {
+	var pw *io.PipeWriter
+
+	c.pr, pw = io.Pipe()
+
+	go func() {
+		w, err := c.encoder(pw)
+		if err == nil {
+			_, err = io.Copy(w, c.body)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+
+		_ = c.body.Close()
+		_ = pw.CloseWithError(err)
+	}()
+}
+
+// decodedBody decodes the body by the codings in the reverse order of applying.
+type decodedBody struct {
+	body    io.ReadCloser
+	codings []string
+	r       io.Reader
+	closers []io.Closer
+	err     error
+}
+
+func (d *decodedBody) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.err = d.init()
+	}
+
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	return d.r.Read(p)
+}
+
+func (d *decodedBody) init() error {
+	var r io.Reader = d.body
+
+	for i := len(d.codings) - 1; i >= 0; i-- {
+		decoder, _ := LookupContentDecoder(d.codings[i])
+
+		rc, err := decoder(r)
+		if err != nil {
+			return err
+		}
+
+		d.closers = append(d.closers, rc)
+		r = rc
+	}
+
+	d.r = r
+
+	return nil
+}
+
+func (d *decodedBody) Close() error {
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		_ = d.closers[i].Close()
+	}
+
+	return d.body.Close()
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+
+// decodeDeflate decodes the zlib format, and the raw deflate format sent by some servers.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2) // nolint gomnd
+	if err != nil && len(header) == 0 {
+		return nil, err
+	}
+
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 { // nolint gomnd
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+func decodeBrotli(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+func decodeZstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.IOReadCloser(), nil
+}
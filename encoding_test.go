@@ -0,0 +1,197 @@
+package gonet
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeContent(t *testing.T, coding string, data []byte) []byte {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser
+
+	if coding == "raw-deflate" { // the raw deflate sent by some servers as deflate
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	} else {
+		encoder, ok := LookupContentEncoder(coding)
+		assert.True(t, ok)
+
+		w, _ = encoder(&buf)
+	}
+
+	_, _ = w.Write(data)
+	assert.Nil(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func encodingServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+
+		body := []byte(`{"name":"bingoo"}`)
+		if r.Method == http.MethodPost { // echo the request body decoded
+			resp := &http.Response{Header: http.Header{"Content-Encoding": r.Header["Content-Encoding"]}, Body: r.Body}
+			DecodeContent(resp)
+
+			body, _ = ioutil.ReadAll(resp.Body)
+			w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+		}
+
+		codings := strings.Split(r.URL.Query().Get("enc"), ",")
+		for _, c := range codings {
+			if c != "" {
+				body = encodeContent(t, c, body)
+			}
+		}
+
+		if codings[0] == "raw-deflate" {
+			codings[0] = "deflate"
+		}
+
+		w.Header().Set("Content-Encoding", strings.Join(codings, ", "))
+		_, _ = w.Write(body)
+	}))
+}
+
+func TestContentDecoding(t *testing.T) {
+	ts := encodingServer(t)
+	defer ts.Close()
+
+	for _, enc := range []string{"", "gzip", "deflate", "raw-deflate", "br", "zstd", "gzip,br"} {
+		req := MustGet(ts.URL + "?enc=" + enc)
+
+		var v struct{ Name string }
+
+		assert.Nil(t, req.ToJSON(&v), enc)
+		assert.Equal(t, "bingoo", v.Name, enc)
+		assert.Equal(t, AcceptEncoding(), req.resp.Header.Get("X-Accept-Encoding"))
+		assert.Equal(t, "", req.resp.Header.Get("Content-Encoding"))
+
+		filename := filepath.Join(t.TempDir(), "name.json")
+		assert.Nil(t, MustGet(ts.URL+"?enc="+enc).ToFile(filename), enc)
+
+		data, _ := ioutil.ReadFile(filename)
+		assert.Equal(t, `{"name":"bingoo"}`, string(data), enc)
+	}
+
+	// no negotiation and decoding if disabled
+	option := NewReqOption()
+	option.Gzip = false
+	req := option.MustGet(ts.URL + "?enc=zstd")
+	data, err := req.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, encodeContent(t, "zstd", []byte(`{"name":"bingoo"}`)), data)
+	assert.Equal(t, "gzip", req.resp.Header.Get("X-Accept-Encoding")) // by http.Transport
+
+	// the Accept-Encoding set explicitly is kept
+	str, err := MustGet(ts.URL+"?enc=br").Header("Accept-Encoding", "br").String()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"bingoo"}`, str)
+
+	// no negotiation for the HEAD and the ranged requests, like http.Transport
+	req = MustHead(ts.URL)
+	rsp, err := req.Response()
+	assert.Nil(t, err)
+	assert.Equal(t, "", rsp.Header.Get("X-Accept-Encoding"))
+
+	req = MustGet(ts.URL).Header("Range", "bytes=0-3")
+	_, err = req.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "", req.resp.Header.Get("X-Accept-Encoding"))
+}
+
+func TestRegisterContentDecoder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "x-upper")
+		_, _ = w.Write([]byte("bingoo"))
+	}))
+	defer ts.Close()
+
+	// the unknown coding is not decoded
+	req := MustGet(ts.URL)
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoo", str)
+	assert.Equal(t, "x-upper", req.resp.Header.Get("Content-Encoding"))
+
+	accepts := AcceptEncoding()
+
+	RegisterContentDecoder("X-Upper", func(r io.Reader) (io.ReadCloser, error) {
+		data, err := ioutil.ReadAll(r)
+		return ioutil.NopCloser(bytes.NewReader(bytes.ToUpper(data))), err
+	})
+	t.Cleanup(func() {
+		encodingsLock.Lock()
+		delete(contentDecoders, "x-upper")
+		acceptEncodings = acceptEncodings[:len(acceptEncodings)-1]
+		encodingsLock.Unlock()
+
+		assert.Equal(t, accepts, AcceptEncoding())
+	})
+
+	assert.Equal(t, "gzip, deflate, br, zstd, x-upper", AcceptEncoding())
+
+	str, err = MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "BINGOO", str)
+}
+
+func TestCompressRequest(t *testing.T) {
+	ts := encodingServer(t)
+	defer ts.Close()
+
+	for _, coding := range []string{"gzip", "zstd"} {
+		req := MustPost(ts.URL + "?enc=" + coding).Compress(coding)
+		assert.Nil(t, req.JSONBody(map[string]string{"name": "huang"}))
+
+		var v struct{ Name string }
+
+		assert.Nil(t, req.ToJSON(&v))
+		assert.Equal(t, "huang", v.Name)
+		assert.Equal(t, coding, req.resp.Header.Get("X-Content-Encoding"))
+	}
+
+	// the small bodies are sent as they are
+	option := NewReqOption()
+	option.Compress, option.CompressMinSize = "gzip", 1024
+	req := option.MustPost(ts.URL).Body(`{"name":"huang"}`)
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"huang"}`, str)
+	assert.Equal(t, "", req.resp.Header.Get("X-Content-Encoding"))
+
+	// the streaming body is compressed on the fly
+	req = MustPost(ts.URL).Compress("zstd")
+	req.req.Body, req.req.ContentLength = ioutil.NopCloser(strings.NewReader("bingoohuang")), -1
+
+	str, err = req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoohuang", str)
+	assert.Equal(t, "zstd", req.resp.Header.Get("X-Content-Encoding"))
+
+	// the replayable body is compressed on the fly as well, and kept replayable
+	r, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("bingoohuang"))
+	assert.Nil(t, CompressRequest(r, "gzip", 0))
+	assert.Equal(t, int64(-1), r.ContentLength)
+
+	body, err := r.GetBody()
+	assert.Nil(t, err)
+
+	sent, _ := ioutil.ReadAll(r.Body)
+	replayed, _ := ioutil.ReadAll(body)
+	assert.Equal(t, encodeContent(t, "gzip", []byte("bingoohuang")), sent)
+	assert.Equal(t, sent, replayed)
+
+	_, err = MustPost(ts.URL).Compress("lz4").Body("abc").String()
+	assert.EqualError(t, err, "no content encoder registered for lz4")
+}
@@ -0,0 +1,69 @@
+package gonet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// HTTPErrorBodyLimit is the maximum size of the body snippet kept in the HTTPError.
+const HTTPErrorBodyLimit = 4096
+
+// HTTPError is the error of the non-2xx response, use errors.As to inspect it.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body is the snippet of the response body, at most HTTPErrorBodyLimit bytes.
+	Body []byte
+}
+
+// NewHTTPError creates the HTTPError of the response.
+// The body snippet is read from the response body, which is left readable from the start.
+func NewHTTPError(resp *http.Response) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+
+	if req := resp.Request; req != nil {
+		e.Method = req.Method
+		e.URL = req.URL.String()
+	}
+
+	if resp.Body != nil && resp.Body != http.NoBody {
+		e.Body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, HTTPErrorBodyLimit))
+		resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(e.Body), resp.Body), Closer: resp.Body}
+	}
+
+	return e
+}
+
+// Error returns the error message with the status and the body snippet.
+func (e *HTTPError) Error() string {
+	msg := e.Status
+	if msg == "" {
+		msg = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	if e.Method != "" {
+		msg = e.Method + " " + e.URL + ": " + msg
+	}
+
+	if len(e.Body) > 0 {
+		msg += ", body: " + string(e.Body)
+	}
+
+	return msg
+}
+
+// multiReadCloser reads from the Reader and closes the Closer.
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
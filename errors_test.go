@@ -0,0 +1,40 @@
+package gonet
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPError(t *testing.T) {
+	long := strings.Repeat("x", HTTPErrorBodyLimit+10)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reason", "quota")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(long))
+	}))
+	defer ts.Close()
+
+	req := MustGet(ts.URL + "/limited")
+	_, err := req.String()
+
+	var he *HTTPError
+
+	assert.True(t, errors.As(err, &he))
+	assert.Equal(t, http.MethodGet, he.Method)
+	assert.Equal(t, ts.URL+"/limited", he.URL)
+	assert.Equal(t, http.StatusTooManyRequests, he.StatusCode)
+	assert.Equal(t, "quota", he.Header.Get("X-Reason"))
+	assert.Equal(t, long[:HTTPErrorBodyLimit], string(he.Body))
+
+	// the response body is still readable fully
+	rsp, _ := req.Response()
+	body, _ := ioutil.ReadAll(rsp.Body)
+	assert.Equal(t, long, string(body))
+}
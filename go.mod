@@ -3,12 +3,14 @@ module github.com/bingoohuang/gonet
 go 1.17
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/bingoohuang/gg v0.0.0-20220310042448-c7510a355c4f
 	github.com/bingoohuang/gor v0.0.0-20200628053500-ec6cb95c0e1b
+	github.com/klauspost/compress v1.15.15
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
+	golang.org/x/net v0.1.0
 )
 
 require (
@@ -17,6 +19,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/thoas/go-funk v0.9.0 // indirect
-	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
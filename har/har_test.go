@@ -0,0 +1,192 @@
+// nolint gomnd
+package har_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/gonet"
+	"github.com/bingoohuang/gonet/har"
+	"github.com/bingoohuang/gonet/man"
+	"github.com/bingoohuang/gonet/retryhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func flakyServer() *httptest.Server {
+	var hits int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"bingoo"}`))
+	}))
+}
+
+func TestRecordAndReplayGonet(t *testing.T) {
+	ts := flakyServer()
+	defer ts.Close()
+
+	rec := har.NewRecorder()
+	option := gonet.NewReqOption()
+	option.Retry = &gonet.RetryPolicy{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}
+	option.Interceptors = []gonet.Interceptor{rec.Interceptor()}
+
+	req := option.MustPost(ts.URL + "/users?id=1")
+	req.Body(`{"id":1}`)
+
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"bingoo"}`, str)
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 0, entries[0].Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, entries[0].Response.Status)
+	assert.Equal(t, 1, entries[1].Attempt)
+	assert.Equal(t, `{"id":1}`, entries[1].Request.PostData.Text)
+	assert.Equal(t, []har.NameValue{{Name: "id", Value: "1"}}, entries[1].Request.QueryString)
+	assert.Equal(t, `{"name":"bingoo"}`, entries[1].Response.Content.Text)
+	assert.Equal(t, int64(17), entries[1].Response.Content.Size)
+	assert.True(t, entries[1].Time > 0)
+	assert.True(t, entries[1].Timings.Wait >= 0)
+
+	filename := filepath.Join(t.TempDir(), "gonet.har")
+	assert.Nil(t, rec.WriteFile(filename))
+
+	ts.Close() // replay offline
+
+	replayer, err := har.LoadReplayer(filename)
+	assert.Nil(t, err)
+
+	option = gonet.NewReqOption()
+	option.Retry = &gonet.RetryPolicy{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}
+	option.Transport = replayer
+
+	req = option.MustPost(ts.URL + "/users?id=1")
+	req.Body(`{"id":1}`)
+
+	str, err = req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"bingoo"}`, str)
+
+	// the body does not match.
+	req = option.MustPost(ts.URL + "/users?id=1")
+	req.Body(`{"id":2}`)
+
+	_, err = req.String()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "har: no recorded response")
+}
+
+type userMan struct {
+	man.T `method:"POST"`
+
+	Create func(man.URL, map[string]interface{}) map[string]string
+}
+
+func TestRecordAndReplayMan(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1001"}`))
+	}))
+	defer ts.Close()
+
+	rec := har.NewRecorder()
+
+	var p userMan
+
+	man.New(&p, man.WithClient(&http.Client{Transport: rec.Transport(nil)}))
+	assert.Equal(t, map[string]string{"id": "1001"}, p.Create(man.URL(ts.URL), map[string]interface{}{"name": "bingoo"}))
+
+	ts.Close()
+
+	var replayed userMan
+
+	man.New(&replayed, man.WithClient(har.NewReplayer(rec.HAR()).Client()))
+	assert.Equal(t, map[string]string{"id": "1001"},
+		replayed.Create(man.URL(ts.URL), map[string]interface{}{"name": "bingoo"}))
+}
+
+func TestRecordRetryHTTP(t *testing.T) {
+	ts := flakyServer()
+	defer ts.Close()
+
+	rec := &har.Recorder{MaxBodySize: 4}
+
+	c := retryhttp.NewClient()
+	c.RetryWaitMin, c.RetryWaitMax = time.Millisecond, time.Millisecond
+	c.HTTPClient.Transport = rec.Transport(c.HTTPClient.Transport)
+
+	resp, err := c.Get(ts.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"bingoo"}`, gonet.ReadString(resp.Body))
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []int{0, 1}, []int{entries[0].Attempt, entries[1].Attempt})
+	assert.Equal(t, `{"na`, entries[1].Response.Content.Text)
+	assert.Equal(t, "truncated", entries[1].Response.Content.Comment)
+	assert.Equal(t, int64(17), entries[1].Response.BodySize)
+
+	// the truncated content is not replayed as the complete one
+	replayer := har.NewReplayer(rec.HAR())
+	resp, err = replayer.Client().Get(ts.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	_, err = replayer.Client().Get(ts.URL)
+	assert.True(t, errors.Is(err, har.ErrTruncatedContent))
+}
+
+func TestRecordRequestBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	rec := &har.Recorder{MaxBodySize: 4}
+
+	var replayable bool
+
+	client := &http.Client{Transport: rec.Transport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		replayable = req.GetBody != nil
+		return http.DefaultTransport.RoundTrip(req)
+	}))}
+
+	// the streaming body is recorded while it is sent, without being made replayable
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, ioutil.NopCloser(strings.NewReader("bingoohuang")))
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoohuang", gonet.ReadString(resp.Body))
+	assert.False(t, replayable)
+
+	// the replayable body is kept replayable
+	resp, err = client.Post(ts.URL, "text/plain", strings.NewReader("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", gonet.ReadString(resp.Body))
+	assert.True(t, replayable)
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, int64(11), entries[0].Request.BodySize)
+	assert.Equal(t, "bing", entries[0].Request.PostData.Text)
+	assert.Equal(t, "truncated", entries[0].Request.PostData.Comment)
+	assert.Equal(t, int64(5), entries[1].Request.BodySize)
+	assert.Equal(t, "text/plain", entries[1].Request.PostData.MimeType)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
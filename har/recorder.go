@@ -0,0 +1,433 @@
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bingoohuang/gonet"
+	"github.com/bingoohuang/gonet/retryhttp"
+)
+
+// DefaultMaxBodySize is the default size limit of the bodies recorded.
+const DefaultMaxBodySize = 64 * 1024
+
+// Recorder records the requests and the responses as the HAR entries.
+// Use its Transport for the http.Client (like ReqOption.Transport, man.WithClient or retryhttp.Client.HTTPClient),
+// or its Interceptor for ReqOption.Interceptors and man.WithInterceptors.
+// Every retry attempt is recorded as an entry with its attempt number.
+// The entry is recorded when the response body is read to the end or closed.
+type Recorder struct {
+	// MaxBodySize is the size limit of the bodies recorded, DefaultMaxBodySize if zero, negative for no bodies.
+	MaxBodySize int
+
+	lock    sync.Mutex
+	entries []Entry
+}
+
+// NewRecorder creates the Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Transport returns the transport which records the requests sent by the next, http.DefaultTransport if nil.
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return r.record(req, next.RoundTrip)
+	})
+}
+
+// Interceptor returns the interceptor which records the requests.
+func (r *Recorder) Interceptor() gonet.Interceptor {
+	return func(req *http.Request, next gonet.Invoker) (*http.Response, error) {
+		return r.record(req, next)
+	}
+}
+
+// Entries returns the entries recorded, sorted by the start time.
+func (r *Recorder) Entries() []Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entries := append([]Entry(nil), r.entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartedDateTime < entries[j].StartedDateTime })
+
+	return entries
+}
+
+// HAR returns the HAR of the entries recorded.
+func (r *Recorder) HAR() *HAR {
+	return &HAR{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: "gonet", Version: "1.0"},
+		Entries: r.Entries(),
+	}}
+}
+
+// WriteFile writes the entries recorded to the HAR file.
+func (r *Recorder) WriteFile(filename string) error {
+	return r.HAR().WriteFile(filename)
+}
+
+// Reset clears the entries recorded.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.entries = nil
+	r.lock.Unlock()
+}
+
+func (r *Recorder) add(e Entry) {
+	r.lock.Lock()
+	r.entries = append(r.entries, e)
+	r.lock.Unlock()
+}
+
+func (r *Recorder) maxBodySize() int {
+	if r.MaxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+
+	return r.MaxBodySize
+}
+
+func (r *Recorder) record(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	t := &timing{start: time.Now()}
+	entry := Entry{
+		StartedDateTime: t.start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Attempt:         retryhttp.Attempt(req.Context()),
+		Request:         r.request(req),
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.trace()))
+	reqBody := r.captureRequestBody(req)
+
+	if reqBody != nil {
+		entry.Request.PostData = &PostData{MimeType: req.Header.Get("Content-Type")}
+	}
+
+	resp, err := send(req)
+	if err != nil {
+		t.end = time.Now()
+		entry.Error = err.Error()
+		entry.Response = Response{Cookies: []Cookie{}, Headers: []NameValue{}, HeadersSize: -1, BodySize: -1}
+		r.finish(&entry, t, reqBody)
+
+		return resp, err
+	}
+
+	entry.Response = Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     cookies(resp.Cookies()),
+		Headers:     headers(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+		Content:     Content{Size: -1, MimeType: resp.Header.Get("Content-Type")},
+	}
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		t.end = time.Now()
+		entry.Response.BodySize, entry.Response.Content.Size = 0, 0
+		r.finish(&entry, t, reqBody)
+
+		return resp, nil
+	}
+
+	capture := &bodyCapture{limit: r.maxBodySize()}
+	resp.Body = &recordingBody{ReadCloser: resp.Body, capture: capture, done: func() {
+		t.end = time.Now()
+		body, size, truncated := capture.result()
+		entry.Response.BodySize, entry.Response.Content.Size = size, size
+		entry.Response.Content.Text, entry.Response.Content.Encoding = encodeBody(body)
+
+		if truncated {
+			entry.Response.Content.Comment = truncatedComment
+		}
+
+		r.finish(&entry, t, reqBody)
+	}}
+
+	return resp, nil
+}
+
+// finish records the entry with the request body captured so far, that is the bytes actually sent.
+func (r *Recorder) finish(entry *Entry, t *timing, reqBody *bodyCapture) {
+	if reqBody != nil {
+		body, size, truncated := reqBody.result()
+		entry.Request.BodySize = size
+		entry.Request.PostData.Text, entry.Request.PostData.Encoding = encodeBody(body)
+
+		if truncated {
+			entry.Request.PostData.Comment = truncatedComment
+		}
+	}
+
+	entry.Timings = t.timings()
+	entry.Time = ms(t.end.Sub(t.start))
+	r.add(*entry)
+}
+
+// captureRequestBody captures the request body while it is sent, up to the limit,
+// and the ones returned by its GetBody, if any, for the resending. It returns nil if there is no body.
+func (r *Recorder) captureRequestBody(req *http.Request) *bodyCapture {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	capture := &bodyCapture{limit: r.maxBodySize()}
+	req.Body = &recordingBody{ReadCloser: req.Body, capture: capture, done: func() {}}
+
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			capture.reset()
+
+			return &recordingBody{ReadCloser: body, capture: capture, done: func() {}}, nil
+		}
+	}
+
+	return capture
+}
+
+// request records the request without the body, which is captured while it is sent.
+func (r *Recorder) request(req *http.Request) Request {
+	hr := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     cookies(req.Cookies()),
+		Headers:     headers(req.Header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+
+	if hr.HTTPVersion == "" {
+		hr.HTTPVersion = "HTTP/1.1"
+	}
+
+	for k, values := range req.URL.Query() {
+		for _, v := range values {
+			hr.QueryString = append(hr.QueryString, NameValue{Name: k, Value: v})
+		}
+	}
+
+	sort.SliceStable(hr.QueryString, func(i, j int) bool { return hr.QueryString[i].Name < hr.QueryString[j].Name })
+
+	return hr
+}
+
+// encodeBody returns the text of the body, and base64 for the binary one.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func headers(h http.Header) []NameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	nvs := make([]NameValue, 0, len(keys))
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			nvs = append(nvs, NameValue{Name: k, Value: v})
+		}
+	}
+
+	return nvs
+}
+
+func cookies(cs []*http.Cookie) []Cookie {
+	hcs := make([]Cookie, 0, len(cs))
+
+	for _, c := range cs {
+		hc := Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+
+		hcs = append(hcs, hc)
+	}
+
+	return hcs
+}
+
+// bodyCapture captures the body up to the limit with its full size, safe for the concurrent use.
+type bodyCapture struct {
+	lock      sync.Mutex
+	limit     int
+	buf       bytes.Buffer
+	size      int64
+	truncated bool
+}
+
+func (c *bodyCapture) write(p []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.size += int64(len(p))
+
+	if remain := c.limit - c.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			c.buf.Write(p[:remain])
+			c.truncated = true
+		} else {
+			c.buf.Write(p)
+		}
+	} else if len(p) > 0 && c.limit >= 0 {
+		c.truncated = true
+	}
+}
+
+func (c *bodyCapture) reset() {
+	c.lock.Lock()
+	c.buf.Reset()
+	c.size, c.truncated = 0, false
+	c.lock.Unlock()
+}
+
+func (c *bodyCapture) result() (body []byte, size int64, truncated bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]byte(nil), c.buf.Bytes()...), c.size, c.truncated
+}
+
+// recordingBody captures the body, and calls done once at the EOF or the closing.
+type recordingBody struct {
+	io.ReadCloser
+	capture *bodyCapture
+	once    sync.Once
+	done    func()
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.capture.write(p[:n])
+
+	if err == io.EOF {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+
+	return err
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(b.done)
+}
+
+// timing collects the time points of the request by the httptrace.
+type timing struct {
+	lock sync.Mutex
+
+	start, end                 time.Time
+	dnsStart, dnsDone          time.Time
+	connectStart, connectDone  time.Time
+	tlsStart, tlsDone          time.Time
+	gotConn, wroteRequest, ttf time.Time
+}
+
+func (t *timing) set(p *time.Time) {
+	t.lock.Lock()
+	if p.IsZero() {
+		*p = time.Now()
+	}
+	t.lock.Unlock()
+}
+
+func (t *timing) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { t.set(&t.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.ttf) },
+	}
+}
+
+func (t *timing) timings() Timings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	connectDone := t.connectDone
+	if t.tlsDone.After(connectDone) { // the connect time includes the ssl time in HAR
+		connectDone = t.tlsDone
+	}
+
+	timings := Timings{
+		DNS:     between(t.dnsStart, t.dnsDone),
+		Connect: between(t.connectStart, connectDone),
+		SSL:     between(t.tlsStart, t.tlsDone),
+		Send:    between(t.gotConn, t.wroteRequest),
+		Wait:    between(t.wroteRequest, t.ttf),
+		Receive: between(t.ttf, t.end),
+		Blocked: -1,
+	}
+
+	if !t.gotConn.IsZero() {
+		blocked := ms(t.gotConn.Sub(t.start))
+		for _, v := range []float64{timings.DNS, timings.Connect} {
+			if v > 0 {
+				blocked -= v
+			}
+		}
+
+		if blocked >= 0 {
+			timings.Blocked = blocked
+		}
+	}
+
+	return timings
+}
+
+func between(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+
+	return ms(to.Sub(from))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,177 @@
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Replayer is the http.RoundTripper which serves the recorded responses,
+// matched by the method, the URL and the body of the requests.
+// The entries of the same request are served in the recorded order, like the retry attempts,
+// and the last one is served repeatedly after all are served.
+// The response whose content is truncated by the recorder gets ErrTruncatedContent,
+// set a large enough Recorder.MaxBodySize to replay it.
+type Replayer struct {
+	// Fallback sends the unmatched requests, which get an error if nil.
+	Fallback http.RoundTripper
+
+	lock    sync.Mutex
+	entries []Entry
+	served  map[int]bool
+}
+
+// NewReplayer creates the Replayer of the HAR.
+func NewReplayer(h *HAR) *Replayer {
+	var entries []Entry
+
+	for _, e := range h.Log.Entries {
+		if e.Error == "" {
+			entries = append(entries, e)
+		}
+	}
+
+	return &Replayer{entries: entries, served: make(map[int]bool)}
+}
+
+// LoadReplayer creates the Replayer of the HAR file.
+func LoadReplayer(filename string) (*Replayer, error) {
+	h, err := ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayer(h), nil
+}
+
+// Client returns the http.Client using the replayer, like for man.WithClient.
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip serves the recorded response of the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = data
+	}
+
+	e, ok := r.match(req, body)
+	if !ok {
+		if r.Fallback != nil {
+			if body != nil { // sends the body read on a shallow copy of the request
+				r2 := *req
+				r2.Body = ioutil.NopCloser(bytes.NewReader(body))
+				req = &r2
+			}
+
+			return r.Fallback.RoundTrip(req)
+		}
+
+		return nil, fmt.Errorf("har: no recorded response for %s %s", req.Method, req.URL)
+	}
+
+	return e.Response.httpResponse(req)
+}
+
+func (r *Replayer) match(req *http.Request, body []byte) (Entry, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	last := -1
+
+	for i, e := range r.entries {
+		if e.Request.Method != req.Method || e.Request.URL != req.URL.String() || !e.Request.bodyMatch(body) {
+			continue
+		}
+
+		if !r.served[i] {
+			r.served[i] = true
+			return e, true
+		}
+
+		last = i
+	}
+
+	if last < 0 {
+		return Entry{}, false
+	}
+
+	return r.entries[last], true
+}
+
+// bodyMatch tells the body matches the recorded one, only the recorded prefix is compared for the truncated one.
+func (q Request) bodyMatch(body []byte) bool {
+	if q.PostData == nil {
+		return len(body) == 0
+	}
+
+	recorded, err := decodeBody(q.PostData.Text, q.PostData.Encoding)
+	if err != nil {
+		return false
+	}
+
+	if q.PostData.Comment == truncatedComment {
+		return bytes.HasPrefix(body, recorded)
+	}
+
+	return bytes.Equal(body, recorded)
+}
+
+func (p Response) httpResponse(req *http.Request) (*http.Response, error) {
+	if p.Content.Comment == truncatedComment {
+		return nil, fmt.Errorf("%w: %s %s", ErrTruncatedContent, req.Method, req.URL)
+	}
+
+	body, err := decodeBody(p.Content.Text, p.Content.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	proto := p.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(proto)
+
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+
+	header := make(http.Header)
+	for _, h := range p.Headers {
+		header.Add(h.Name, h.Value)
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		Status:        strings.TrimSpace(strconv.Itoa(p.Status) + " " + p.StatusText),
+		StatusCode:    p.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func decodeBody(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+
+	return []byte(text), nil
+}
@@ -0,0 +1,447 @@
+package gonet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // nolint gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderSignature is the header of the HMAC signature, like
+// keyId=k1,algorithm=hmac-sha256,timestamp=1600000000,nonce=...,headers=host;content-type,signature=...
+const HeaderSignature = "X-Signature"
+
+// DefaultHMACAlgorithm is the default algorithm of the HMAC signatures.
+const DefaultHMACAlgorithm = "hmac-sha256"
+
+// DefaultSignatureSkew is the default clock skew window of the HMAC signatures.
+const DefaultSignatureSkew = 5 * time.Minute
+
+// DefaultMaxSignedBodySize is the default max size of the request bodies verified by the HMACVerifier.
+const DefaultMaxSignedBodySize = 10 << 20
+
+// nolint gochecknoglobals
+var (
+	// ErrSignatureMissing is the error of the requests without the signature.
+	ErrSignatureMissing = errors.New("signature missing")
+	// ErrSignatureInvalid is the error of the signatures mismatched, or signed by the unknown keys or algorithms.
+	ErrSignatureInvalid = errors.New("signature invalid")
+	// ErrSignatureExpired is the error of the timestamps out of the clock skew window.
+	ErrSignatureExpired = errors.New("signature expired")
+	// ErrSignatureReplayed is the error of the nonces used before.
+	ErrSignatureReplayed = errors.New("signature replayed")
+	// ErrBodyTooLarge is the error of the request bodies larger than the HMACVerifier.MaxBodySize.
+	ErrBodyTooLarge = errors.New("request body too large")
+
+	hmacAlgorithms = map[string]func() hash.Hash{
+		"hmac-sha1":   sha1.New,
+		"hmac-sha256": sha256.New,
+		"hmac-sha512": sha512.New,
+	}
+)
+
+// HMACSigner signs the requests by HMAC over the method, the path, the sorted query, the selected headers,
+// the SHA-256 digest of the body, the timestamp and the nonce.
+// Use its Interceptor by ReqOption.Interceptors, HTTPReq.Intercept or man.WithInterceptors.
+type HMACSigner struct {
+	KeyID string
+	Key   []byte
+	// Algorithm is one of hmac-sha1, hmac-sha256 and hmac-sha512, DefaultHMACAlgorithm by default.
+	Algorithm string
+	// Headers are the names of the signed headers besides the host, the absent ones are signed as empty.
+	Headers []string
+	// Now returns the current time, time.Now will be used if nil.
+	Now func() time.Time
+}
+
+// Interceptor returns the interceptor which signs the requests.
+func (s *HMACSigner) Interceptor() Interceptor {
+	return func(req *http.Request, next Invoker) (*http.Response, error) {
+		if err := s.Sign(req); err != nil {
+			return nil, err
+		}
+
+		return next(req)
+	}
+}
+
+// Sign signs the request by setting the HeaderSignature.
+func (s *HMACSigner) Sign(req *http.Request) error {
+	algorithm := s.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultHMACAlgorithm
+	}
+
+	h, ok := hmacAlgorithms[algorithm]
+	if !ok {
+		return fmt.Errorf("unsupported HMAC algorithm %s", algorithm)
+	}
+
+	bodyDigest, err := requestBodyDigest(req, 0)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	nonce := make([]byte, 16) // nolint gomnd
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	p := signatureParams{
+		keyID:     s.KeyID,
+		algorithm: algorithm,
+		timestamp: now().Unix(),
+		nonce:     hex.EncodeToString(nonce),
+		headers:   append([]string{"host"}, lowerHeaders(s.Headers)...),
+	}
+
+	p.signature = base64.StdEncoding.EncodeToString(
+		hmacSum(h, s.Key, canonicalRequest(req, req.Host, &p, bodyDigest)))
+	req.Header.Set(HeaderSignature, p.String())
+
+	return nil
+}
+
+// HMACVerifier verifies the HMAC signatures of the HMACSigner, and rejects the replayed nonces.
+type HMACVerifier struct {
+	// Keys are the keys by the key IDs.
+	Keys map[string][]byte
+	// Algorithm is the accepted algorithm, DefaultHMACAlgorithm by default.
+	Algorithm string
+	// Headers are the names of the headers which are required to be signed.
+	Headers []string
+	// MaxSkew is the clock skew window of the timestamps, DefaultSignatureSkew by default.
+	MaxSkew time.Duration
+	// MaxBodySize is the max size of the request bodies, which are read in memory to verify,
+	// DefaultMaxSignedBodySize by default. The larger ones are rejected by ErrBodyTooLarge.
+	MaxBodySize int64
+	// Now returns the current time, time.Now will be used if nil.
+	Now func() time.Time
+
+	lock   sync.Mutex
+	nonces map[string]time.Time
+}
+
+// Verify verifies the signature of the request, the body is read and restored.
+func (v *HMACVerifier) Verify(r *http.Request) error {
+	value := r.Header.Get(HeaderSignature)
+	if value == "" {
+		return ErrSignatureMissing
+	}
+
+	p, err := parseSignatureParams(value)
+	if err != nil {
+		return err
+	}
+
+	algorithm := v.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultHMACAlgorithm
+	}
+
+	key, ok := v.Keys[p.keyID]
+	if !ok || p.algorithm != algorithm || hmacAlgorithms[algorithm] == nil {
+		return ErrSignatureInvalid
+	}
+
+	for _, name := range lowerHeaders(v.Headers) {
+		if !containsString(p.headers, name) {
+			return fmt.Errorf("%w: header %s is not signed", ErrSignatureInvalid, name)
+		}
+	}
+
+	now, skew := v.now(), v.maxSkew()
+	signedAt := time.Unix(p.timestamp, 0)
+
+	if signedAt.Before(now.Add(-skew)) || signedAt.After(now.Add(skew)) {
+		return ErrSignatureExpired
+	}
+
+	bodyDigest, err := requestBodyDigest(r, v.maxBodySize())
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(p.signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	expected := hmacSum(hmacAlgorithms[algorithm], key, canonicalRequest(r, r.Host, p, bodyDigest))
+	if !hmac.Equal(signature, expected) {
+		return ErrSignatureInvalid
+	}
+
+	return v.checkNonce(p.keyID+":"+p.nonce, signedAt.Add(skew), now)
+}
+
+func (v *HMACVerifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+
+	return time.Now()
+}
+
+func (v *HMACVerifier) maxSkew() time.Duration {
+	if v.MaxSkew > 0 {
+		return v.MaxSkew
+	}
+
+	return DefaultSignatureSkew
+}
+
+func (v *HMACVerifier) maxBodySize() int64 {
+	if v.MaxBodySize > 0 {
+		return v.MaxBodySize
+	}
+
+	return DefaultMaxSignedBodySize
+}
+
+// checkNonce records the nonce until its expiry, the ones of the expired signatures are pruned.
+func (v *HMACVerifier) checkNonce(nonce string, expiry, now time.Time) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.nonces == nil {
+		v.nonces = make(map[string]time.Time)
+	}
+
+	for k, t := range v.nonces {
+		if t.Before(now) {
+			delete(v.nonces, k)
+		}
+	}
+
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrSignatureReplayed
+	}
+
+	v.nonces[nonce] = expiry
+
+	return nil
+}
+
+// Handler returns the middleware which responds 401 Unauthorized for the requests failed to verify,
+// or 413 Request Entity Too Large for the bodies larger than the MaxBodySize.
+func (v *HMACVerifier) Handler(next http.Handler) http.Handler {
+	return VerifyHMAC(next.ServeHTTP, v)
+}
+
+type signatureParams struct {
+	keyID, algorithm, nonce, signature string
+	timestamp                          int64
+	headers                            []string
+}
+
+func (p signatureParams) String() string {
+	return fmt.Sprintf("keyId=%s,algorithm=%s,timestamp=%d,nonce=%s,headers=%s,signature=%s",
+		p.keyID, p.algorithm, p.timestamp, p.nonce, strings.Join(p.headers, ";"), p.signature)
+}
+
+func parseSignatureParams(value string) (*signatureParams, error) {
+	p := &signatureParams{}
+
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2) // nolint gomnd
+		if len(kv) != 2 {                                     // nolint gomnd
+			return nil, fmt.Errorf("%w: bad parameter %q", ErrSignatureInvalid, part)
+		}
+
+		switch kv[0] {
+		case "keyId":
+			p.keyID = kv[1]
+		case "algorithm":
+			p.algorithm = kv[1]
+		case "timestamp":
+			ts, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad timestamp %q", ErrSignatureInvalid, kv[1])
+			}
+
+			p.timestamp = ts
+		case "nonce":
+			p.nonce = kv[1]
+		case "headers":
+			p.headers = strings.Split(kv[1], ";")
+		case "signature":
+			p.signature = kv[1] // base64 may end with =, SplitN keeps it
+		}
+	}
+
+	if p.nonce == "" || p.signature == "" || p.timestamp == 0 || !containsString(p.headers, "host") {
+		return nil, fmt.Errorf("%w: incomplete parameters", ErrSignatureInvalid)
+	}
+
+	return p, nil
+}
+
+// canonicalRequest builds the string to sign, one item a line:
+// the method, the escaped path, the sorted query, the signed headers as name:value,
+// the timestamp, the nonce and the hex SHA-256 digest of the body.
+func canonicalRequest(r *http.Request, host string, p *signatureParams, bodyDigest string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(r.Method + "\n")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	b.WriteString(path + "\n")
+	b.WriteString(canonicalQuery(r.URL.Query()) + "\n")
+
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	for _, name := range p.headers {
+		value := host
+		if name != "host" {
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+
+		b.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	b.WriteString(strconv.FormatInt(p.timestamp, 10) + "\n")
+	b.WriteString(p.nonce + "\n")
+	b.WriteString(bodyDigest)
+
+	return b.Bytes()
+}
+
+// canonicalQuery encodes the query sorted by the keys and then the values.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var parts []string
+
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// requestBodyDigest returns the hex SHA-256 digest of the request body, and keeps the body readable.
+// The body larger than the maxSize, if positive, is rejected by ErrBodyTooLarge.
+func requestBodyDigest(r *http.Request, maxSize int64) (string, error) {
+	h := sha256.New()
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if maxSize > 0 && r.ContentLength > maxSize {
+		return "", ErrBodyTooLarge
+	}
+
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return "", err
+		}
+
+		defer body.Close()
+
+		n, err := io.Copy(h, limitBody(body, maxSize))
+		if err != nil {
+			return "", err
+		}
+
+		if maxSize > 0 && n > maxSize {
+			return "", ErrBodyTooLarge
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	data, err := ioutil.ReadAll(limitBody(r.Body, maxSize))
+	_ = r.Body.Close()
+
+	if err != nil {
+		return "", err
+	}
+
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return "", ErrBodyTooLarge
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	r.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(data)), nil }
+	_, _ = h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// limitBody limits the reading to maxSize+1 bytes to detect the body larger than the maxSize, if positive.
+func limitBody(r io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return r
+	}
+
+	return io.LimitReader(r, maxSize+1)
+}
+
+func hmacSum(h func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(h, key)
+	_, _ = mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+func lowerHeaders(names []string) []string {
+	lower := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" && name != "host" {
+			lower = append(lower, name)
+		}
+	}
+
+	return lower
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package gonet
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACSignAndVerify(t *testing.T) {
+	verifier := &HMACVerifier{Keys: map[string][]byte{"k1": []byte("s3cret")}, Headers: []string{"Content-Type"}}
+
+	ts := httptest.NewServer(VerifyHMAC(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}, verifier))
+	defer ts.Close()
+
+	signer := &HMACSigner{KeyID: "k1", Key: []byte("s3cret"), Headers: []string{"Content-Type"}}
+
+	var signed *http.Request
+
+	req := MustPost(ts.URL+"/api/orders?b=2&a=1&a=0").
+		Header("Content-Type", "application/json").
+		Intercept(signer.Interceptor(), func(req *http.Request, next Invoker) (*http.Response, error) {
+			signed = req
+			return next(req)
+		})
+	req.Body(`{"id":1}`)
+
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":1}`, str)
+
+	// replay the same signed request.
+	replay, _ := http.NewRequest(http.MethodPost, signed.URL.String(), strings.NewReader(`{"id":1}`))
+	replay.Header = signed.Header.Clone()
+	resp, err := http.DefaultClient.Do(replay)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, ErrSignatureReplayed.Error()+"\n", ReadString(resp.Body))
+
+	// the unsigned request.
+	_, err = MustGet(ts.URL).String()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrSignatureMissing.Error())
+
+	// the content type is not signed.
+	_, err = MustGet(ts.URL).Intercept((&HMACSigner{KeyID: "k1", Key: []byte("s3cret")}).Interceptor()).String()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "header content-type is not signed")
+}
+
+func TestHMACVerifyRejects(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	verifier := &HMACVerifier{
+		Keys: map[string][]byte{"k1": []byte("s3cret")}, Algorithm: "hmac-sha512",
+		MaxSkew: time.Minute, Now: func() time.Time { return now },
+	}
+	signer := &HMACSigner{KeyID: "k1", Key: []byte("s3cret"), Algorithm: "hmac-sha512", Now: func() time.Time { return now }}
+
+	newReq := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "http://example.com/a%20b?x=1", strings.NewReader(body))
+		assert.Nil(t, signer.Sign(r))
+
+		return r
+	}
+
+	assert.Nil(t, verifier.Verify(newReq("hello")))
+
+	tampered := newReq("hello")
+	tampered.Body = ioutil.NopCloser(strings.NewReader("hell0"))
+	tampered.GetBody = nil
+	assert.True(t, errors.Is(verifier.Verify(tampered), ErrSignatureInvalid))
+
+	tampered = newReq("hello")
+	tampered.URL.RawQuery = "x=2"
+	assert.True(t, errors.Is(verifier.Verify(tampered), ErrSignatureInvalid))
+
+	signer.KeyID = "unknown"
+	assert.True(t, errors.Is(verifier.Verify(newReq("hello")), ErrSignatureInvalid))
+
+	signer.KeyID, signer.Algorithm = "k1", "hmac-sha256"
+	assert.True(t, errors.Is(verifier.Verify(newReq("hello")), ErrSignatureInvalid))
+
+	signer.Algorithm = "hmac-sha512"
+	expired := newReq("hello")
+	now = now.Add(2 * time.Minute)
+	assert.True(t, errors.Is(verifier.Verify(expired), ErrSignatureExpired))
+}
+
+func TestHMACVerifyMaxBodySize(t *testing.T) {
+	verifier := &HMACVerifier{Keys: map[string][]byte{"k1": []byte("s3cret")}, MaxBodySize: 8}
+
+	ts := httptest.NewServer(verifier.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})))
+	defer ts.Close()
+
+	signer := &HMACSigner{KeyID: "k1", Key: []byte("s3cret")}
+
+	str, err := MustPost(ts.URL).Intercept(signer.Interceptor()).Body("12345678").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "12345678", str)
+
+	resp, err := MustPost(ts.URL).Intercept(signer.Interceptor()).Body("123456789").Response()
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// the body of the unknown length is limited by reading
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("123456789"))
+	assert.Nil(t, signer.Sign(r))
+
+	r.ContentLength = -1
+	assert.True(t, errors.Is(verifier.Verify(r), ErrBodyTooLarge))
+}
@@ -3,6 +3,7 @@ package gonet
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"mime"
 	"net"
@@ -52,6 +53,25 @@ func DumpRequest(fn http.HandlerFunc, body bool, dumper func(error, []byte)) htt
 	}
 }
 
+// VerifyHMAC verifies the HMAC signatures of the requests by the verifier,
+// and responds 401 Unauthorized with the error for the failed ones,
+// or 413 Request Entity Too Large for the bodies larger than its MaxBodySize.
+func VerifyHMAC(fn http.HandlerFunc, verifier *HMACVerifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := verifier.Verify(r); err != nil {
+			code := http.StatusUnauthorized
+			if errors.Is(err, ErrBodyTooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		fn(w, r)
+	}
+}
+
 // DetectContentType ...
 func DetectContentType(name string) (t string) {
 	if t = mime.TypeByExtension(filepath.Ext(name)); t == "" {
@@ -0,0 +1,105 @@
+package gonet
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Protocol is the HTTP protocol mode of the clients and the servers.
+type Protocol int
+
+const (
+	// ProtocolHTTP1 uses HTTP/1.1 only, the default one.
+	ProtocolHTTP1 Protocol = iota
+	// ProtocolHTTP2 uses HTTP/2 over TLS negotiated by ALPN, and falls back to HTTP/1.1
+	// for the http:// urls or the servers without HTTP/2.
+	ProtocolHTTP2
+	// ProtocolH2C uses HTTP/2 over cleartext TCP with the prior knowledge (h2c) for the http:// urls,
+	// and HTTP/2 over TLS for the https:// urls like ProtocolHTTP2.
+	// The proxy is not used for the h2c requests.
+	ProtocolH2C
+)
+
+// String returns the name of the protocol.
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolHTTP2:
+		return "HTTP/2"
+	case ProtocolH2C:
+		return "h2c"
+	default:
+		return "HTTP/1.1"
+	}
+}
+
+// configureTransport configures the HTTP/2 support of the transport for the protocol.
+// It returns the HTTP/2 transport to close its idle connections, nil for ProtocolHTTP1.
+func configureTransport(t *http.Transport, protocol Protocol, dialer DialerTimeoutBean) (*http2.Transport, error) {
+	if protocol == ProtocolHTTP1 {
+		return nil, nil
+	}
+
+	// http2.ConfigureTransports adds h2 to the NextProtos of the TLS config, do not change the one of the user.
+	t.TLSClientConfig = t.TLSClientConfig.Clone()
+
+	t2, err := http2.ConfigureTransports(t)
+	if err != nil || protocol != ProtocolH2C {
+		return t2, err
+	}
+
+	h2cTransport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+
+	t.RegisterProtocol("http", h2cTransport)
+
+	return h2cTransport, nil
+}
+
+// ConfigureServer configures the server for the protocol.
+// ProtocolHTTP1 disables HTTP/2, ProtocolHTTP2 enables HTTP/2 over TLS,
+// and ProtocolH2C enables HTTP/2 over TLS and h2c, both the prior knowledge and the upgrade from HTTP/1.1.
+// It should be called after the Handler and the TLSConfig are set.
+func ConfigureServer(srv *http.Server, protocol Protocol) error {
+	switch protocol {
+	case ProtocolHTTP1:
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+
+		if c := srv.TLSConfig; c != nil {
+			protos := make([]string, 0, len(c.NextProtos))
+			for _, proto := range c.NextProtos {
+				if proto != http2.NextProtoTLS {
+					protos = append(protos, proto)
+				}
+			}
+
+			c.NextProtos = protos
+		}
+
+		return nil
+	case ProtocolH2C:
+		h2s := &http2.Server{}
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			return err
+		}
+
+		handler := srv.Handler
+		if handler == nil {
+			handler = http.DefaultServeMux
+		}
+
+		srv.Handler = h2c.NewHandler(handler, h2s)
+
+		return nil
+	default:
+		return http2.ConfigureServer(srv, &http2.Server{})
+	}
+}
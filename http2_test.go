@@ -0,0 +1,115 @@
+package gonet
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func protoHandler(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(r.Proto)) }
+
+func TestProtocolHTTP2(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(protoHandler))
+	assert.Nil(t, ConfigureServer(ts.Config, ProtocolHTTP2))
+	ts.TLS = ts.Config.TLSConfig
+	ts.StartTLS()
+
+	defer ts.Close()
+
+	pool := NewTransportPool()
+	defer pool.Close()
+
+	tlsConfig := ts.Client().Transport.(*http.Transport).TLSClientConfig
+
+	for protocol, expected := range map[Protocol]string{
+		ProtocolHTTP1: "HTTP/1.1",
+		ProtocolHTTP2: "HTTP/2.0",
+		ProtocolH2C:   "HTTP/2.0",
+	} {
+		option := NewReqOption()
+		option.Pool, option.TLSClientConfig, option.Protocol = pool, tlsConfig, protocol
+
+		s, err := option.MustGet(ts.URL).String()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, s, protocol.String())
+	}
+
+	assert.Empty(t, tlsConfig.NextProtos)
+	assert.Equal(t, 3, pool.Len())
+}
+
+func TestProtocolH2C(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(protoHandler))
+	assert.Nil(t, ConfigureServer(ts.Config, ProtocolH2C))
+	ts.Start()
+
+	defer ts.Close()
+
+	for protocol, expected := range map[Protocol]string{
+		ProtocolHTTP1: "HTTP/1.1",
+		ProtocolHTTP2: "HTTP/1.1",
+		ProtocolH2C:   "HTTP/2.0",
+	} {
+		s, err := MustGet(ts.URL).Protocol(protocol).String()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, s, protocol.String())
+	}
+}
+
+func TestProtocolH2CDialContext(t *testing.T) {
+	// the SOCKS5 proxy which never responds
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	defer ln.Close()
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			defer c.Close()
+		}
+	}()
+
+	option := NewReqOption()
+	option.Protocol = ProtocolH2C
+	option.ProxyConfig, _ = ParseProxy("socks5h://"+ln.Addr().String(), "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	req, err := option.GetCtx(ctx, "http://example.com/")
+	assert.Nil(t, err)
+
+	start := time.Now()
+	_, err = req.String()
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestServerProtocolHTTP1(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(protoHandler))
+	ts.Config.TLSConfig = &tls.Config{NextProtos: []string{"h2", "http/1.1"}} // nolint gosec
+	assert.Nil(t, ConfigureServer(ts.Config, ProtocolHTTP1))
+	ts.TLS = ts.Config.TLSConfig
+	ts.StartTLS()
+
+	defer ts.Close()
+
+	option := NewReqOption()
+	option.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	option.Protocol = ProtocolHTTP2
+
+	s, err := option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1", s)
+}
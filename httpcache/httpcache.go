@@ -0,0 +1,631 @@
+// Package httpcache provides the http.RoundTripper which caches the responses as a private cache, by RFC 7234.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// XFromCache is the header set to "1" for the responses served from the cache.
+	XFromCache = "X-From-Cache"
+
+	// xStoredAt is the internal header of the time when the response is received.
+	xStoredAt = "X-Httpcache-Stored-At"
+	// xVaried is the internal header prefix of the request header values selected by the Vary.
+	xVaried = "X-Httpcache-Varied-"
+
+	// DefaultMaxBodySize is the default max size of the response bodies cached.
+	DefaultMaxBodySize = 10 << 20
+
+	// varyIndexMagic is the first line of the vary index.
+	varyIndexMagic = "httpcache-vary-index"
+)
+
+// Storage stores the serialized responses.
+type Storage interface {
+	// Get returns the stored data of the key.
+	Get(key string) ([]byte, bool)
+	// Set stores the data of the key.
+	Set(key string, data []byte)
+	// Delete deletes the data of the key.
+	Delete(key string)
+}
+
+// Transport is the http.RoundTripper which serves the GET requests from the cache when the cached responses are fresh,
+// and revalidates the stale ones by the ETag/Last-Modified. It honors the Cache-Control, Expires and Vary.
+type Transport struct {
+	// Transport is the underlying transport, http.DefaultTransport will be used if nil.
+	Transport http.RoundTripper
+	// Storage is the storage of the cached responses.
+	Storage Storage
+	// Now returns the current time, time.Now will be used if nil.
+	Now func() time.Time
+	// MaxBodySize is the max size of the response bodies cached, DefaultMaxBodySize if zero or negative.
+	// The larger responses are passed through without being cached.
+	MaxBodySize int64
+
+	// locks serialize the updates of the vary indexes, striped by the keys.
+	locks [64]sync.Mutex
+}
+
+// NewTransport creates the Transport with the storage.
+func NewTransport(storage Storage) *Transport {
+	return &Transport{Storage: storage}
+}
+
+// NewMemoryTransport creates the Transport with the in-memory LRU storage of the max entries.
+func NewMemoryTransport(maxEntries int) *Transport {
+	return NewTransport(NewMemoryStorage(maxEntries))
+}
+
+// Client returns the http.Client using the transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// FromCache tells the response is served from the cache.
+func FromCache(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get(XFromCache) == "1"
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+
+	return http.DefaultTransport
+}
+
+func (t *Transport) maxBodySize() int64 {
+	if t.MaxBodySize > 0 {
+		return t.MaxBodySize
+	}
+
+	return DefaultMaxBodySize
+}
+
+func (t *Transport) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+
+	return time.Now()
+}
+
+// cacheKey returns the key of the request.
+// The responses with the Vary are stored by the variant keys, which are listed by the vary index stored by the key.
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+// variantKey returns the key of the response variant selected by the values of the Vary headers of the request.
+func variantKey(key string, names []string, req *http.Request) string {
+	h := sha256.New()
+
+	for _, name := range names {
+		_, _ = io.WriteString(h, name+":"+strings.Join(req.Header.Values(name), ",")+"\n")
+	}
+
+	return key + "#vary-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// varyIndex is the index of the response variants of a key, by the header names of their Vary.
+type varyIndex struct {
+	names    []string
+	variants []string
+}
+
+func parseVaryIndex(data []byte) (*varyIndex, bool) {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 || lines[0] != varyIndexMagic { // nolint gomnd
+		return nil, false
+	}
+
+	return &varyIndex{names: strings.Split(lines[1], ","), variants: lines[2:]}, true
+}
+
+func (x *varyIndex) bytes() []byte {
+	return []byte(strings.Join(append([]string{varyIndexMagic, strings.Join(x.names, ",")}, x.variants...), "\n"))
+}
+
+// index returns the vary index stored by the key, nil if there is none.
+func (t *Transport) index(key string) *varyIndex {
+	if data, ok := t.Storage.Get(key); ok {
+		if x, ok := parseVaryIndex(data); ok {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// lock locks the updates of the stored responses of the key, and returns the unlocking function.
+func (t *Transport) lock(key string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	l := &t.locks[h.Sum32()%uint32(len(t.locks))]
+	l.Lock()
+
+	return l.Unlock
+}
+
+// invalidate deletes the responses stored by the key.
+func (t *Transport) invalidate(key string) {
+	defer t.lock(key)()
+
+	t.delete(key)
+}
+
+// delete deletes the response stored by the key, or the index and all the variants of it.
+// The key should be locked by lock.
+func (t *Transport) delete(key string) {
+	if x := t.index(key); x != nil {
+		for _, v := range x.variants {
+			t.Storage.Delete(v)
+		}
+	}
+
+	t.Storage.Delete(key)
+}
+
+// RoundTrip serves the request from the cache if possible, otherwise sends it by the underlying transport.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		resp, err := t.transport().RoundTrip(req)
+		if err == nil && isUnsafe(req.Method) && resp.StatusCode < 400 { // nolint gomnd
+			t.invalidate(key) // invalidate the cached responses
+		}
+
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.transport().RoundTrip(req)
+	}
+
+	cached := t.cachedResponse(key, req)
+
+	if cached != nil && t.fresh(req, reqCC, cached) {
+		return t.serve(cached), nil
+	}
+
+	if _, ok := reqCC["only-if-cached"]; ok {
+		closeBody(cached)
+		return gatewayTimeout(req), nil
+	}
+
+	if cached == nil {
+		return t.fetch(key, req, nil)
+	}
+
+	return t.revalidate(key, req, cached)
+}
+
+// cachedResponse returns the cached response of the key matching the Vary of the request.
+func (t *Transport) cachedResponse(key string, req *http.Request) *http.Response {
+	data, ok := t.Storage.Get(key)
+	if !ok {
+		return nil
+	}
+
+	if x, ok := parseVaryIndex(data); ok {
+		if key = variantKey(key, x.names, req); !containsString(x.variants, key) {
+			return nil
+		}
+
+		if data, ok = t.Storage.Get(key); !ok {
+			return nil
+		}
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		t.Storage.Delete(key)
+		return nil
+	}
+
+	for _, name := range varyHeaders(resp.Header) {
+		if resp.Header.Get(xVaried+name) != req.Header.Get(name) {
+			closeBody(resp)
+			return nil
+		}
+	}
+
+	return resp
+}
+
+// serve returns the cached response with the headers of the cache.
+func (t *Transport) serve(resp *http.Response) *http.Response {
+	resp.Header.Set("Age", strconv.FormatInt(int64(t.age(resp)/time.Second), 10))
+	resp.Header.Set(XFromCache, "1")
+	stripInternal(resp.Header)
+
+	return resp
+}
+
+// revalidate sends the conditional request of the stale cached response,
+// and serves the cached response updated by the 304 Not Modified.
+func (t *Transport) revalidate(key string, req *http.Request, cached *http.Response) (*http.Response, error) {
+	etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		closeBody(cached)
+		return t.fetch(key, req, nil)
+	}
+
+	condReq := req.Clone(req.Context())
+	if etag != "" && condReq.Header.Get("If-None-Match") == "" {
+		condReq.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified != "" && condReq.Header.Get("If-Modified-Since") == "" {
+		condReq.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	return t.fetch(key, condReq, cached)
+}
+
+// fetch sends the request, and stores the cacheable response when its body is read to the end.
+// The cached response is updated and served for the 304 Not Modified.
+func (t *Transport) fetch(key string, req *http.Request, cached *http.Response) (*http.Response, error) {
+	requestTime := t.now()
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		closeBody(cached)
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		closeBody(resp)
+
+		for k, v := range resp.Header {
+			if !hopByHop(k) && k != "Content-Length" {
+				cached.Header[k] = v
+			}
+		}
+
+		cached.Header.Set(xStoredAt, requestTime.Format(time.RFC3339Nano))
+		t.store(key, req, cached)
+
+		return t.serve(cached), nil
+	}
+
+	closeBody(cached)
+
+	if !cacheable(req, resp) || resp.ContentLength > t.maxBodySize() {
+		if resp.StatusCode < 500 { // nolint gomnd
+			t.invalidate(key)
+		}
+
+		return resp, nil
+	}
+
+	resp.Header.Set(xStoredAt, requestTime.Format(time.RFC3339Nano))
+
+	for _, name := range varyHeaders(resp.Header) {
+		resp.Header.Set(xVaried+name, req.Header.Get(name))
+	}
+
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	stripInternal(resp.Header)
+
+	resp.Body = &cachingReader{r: resp.Body, limit: t.maxBodySize(), onEOF: func(body []byte) {
+		stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+		stored.ContentLength = int64(len(body))
+		t.store(key, req, &stored)
+	}}
+
+	return resp, nil
+}
+
+// store serializes and stores the response, whose body is consumed.
+func (t *Transport) store(key string, req *http.Request, resp *http.Response) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del(XFromCache)
+
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// the index is loaded, modified and stored under the lock, not to lose the variants stored concurrently.
+	defer t.lock(key)()
+
+	names := varyHeaders(resp.Header)
+	x := t.index(key)
+
+	if len(names) == 0 {
+		if x != nil {
+			t.delete(key)
+		}
+
+		t.Storage.Set(key, data)
+
+		return
+	}
+
+	if x == nil || strings.Join(x.names, ",") != strings.Join(names, ",") {
+		t.delete(key)
+		x = &varyIndex{names: names}
+	}
+
+	variant := variantKey(key, names, req)
+	if !containsString(x.variants, variant) {
+		x.variants = append(x.variants, variant)
+	}
+
+	t.Storage.Set(variant, data)
+	t.Storage.Set(key, x.bytes())
+}
+
+// cacheable tells the response can be stored.
+func cacheable(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+
+	_, maxAge := cc["max-age"]
+
+	return maxAge || resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// fresh tells the cached response is fresh for the request.
+func (t *Transport) fresh(req *http.Request, reqCC cacheControl, resp *http.Response) bool {
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+
+	if _, ok := reqCC["no-cache"]; ok || req.Header.Get("Pragma") == "no-cache" && len(reqCC) == 0 {
+		return false
+	}
+
+	lifetime := freshnessLifetime(resp.Header, respCC)
+	age := t.age(resp)
+
+	if maxAge, ok := reqCC.duration("max-age"); ok && maxAge < lifetime {
+		lifetime = maxAge
+	}
+
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		age += minFresh
+	}
+
+	if v, ok := reqCC["max-stale"]; ok {
+		if _, mustRevalidate := respCC["must-revalidate"]; !mustRevalidate {
+			if v == "" {
+				return true
+			}
+
+			if maxStale, ok := reqCC.duration("max-stale"); ok {
+				age -= maxStale
+			}
+		}
+	}
+
+	return age < lifetime
+}
+
+// freshnessLifetime returns the freshness lifetime by the max-age or the Expires.
+func freshnessLifetime(h http.Header, cc cacheControl) time.Duration {
+	if maxAge, ok := cc.duration("max-age"); ok {
+		return maxAge
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // the invalid Expires means already expired
+		}
+
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			return 0
+		}
+
+		return expiresTime.Sub(date)
+	}
+
+	return 0
+}
+
+// age returns the current age of the cached response.
+func (t *Transport) age(resp *http.Response) time.Duration {
+	storedAt, err := time.Parse(time.RFC3339Nano, resp.Header.Get(xStoredAt))
+	if err != nil {
+		return 0
+	}
+
+	age := time.Duration(0)
+
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil && storedAt.After(date) {
+		age = storedAt.Sub(date)
+	}
+
+	if v, err := strconv.ParseInt(resp.Header.Get("Age"), 10, 64); err == nil && time.Duration(v)*time.Second > age {
+		age = time.Duration(v) * time.Second
+	}
+
+	return age + t.now().Sub(storedAt)
+}
+
+// cacheControl is the parsed Cache-Control directives.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			if p := strings.IndexByte(part, '='); p >= 0 {
+				cc[strings.ToLower(strings.TrimSpace(part[:p]))] = strings.Trim(strings.TrimSpace(part[p+1:]), `"`)
+			} else {
+				cc[strings.ToLower(part)] = ""
+			}
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) duration(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// varyHeaders returns the canonical header names listed by the Vary.
+func varyHeaders(h http.Header) []string {
+	var names []string
+
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+func stripInternal(h http.Header) {
+	for k := range h {
+		if k == xStoredAt || strings.HasPrefix(k, xVaried) {
+			delete(h, k)
+		}
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hopByHop(name string) bool {
+	switch name {
+	case "Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+		"Te", "Trailer", "Transfer-Encoding", "Upgrade":
+		return true
+	}
+
+	return false
+}
+
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	return true
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+func closeBody(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+}
+
+// cachingReader buffers the body read, and calls onEOF with the whole body at the EOF.
+// It stops buffering, and never calls onEOF, once the body exceeds the limit.
+type cachingReader struct {
+	r     io.ReadCloser
+	limit int64
+	buf   bytes.Buffer
+	onEOF func(body []byte)
+}
+
+func (c *cachingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+
+	if c.onEOF != nil {
+		if int64(c.buf.Len()+n) > c.limit {
+			c.buf, c.onEOF = bytes.Buffer{}, nil
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && c.onEOF != nil {
+		c.onEOF(c.buf.Bytes())
+		c.onEOF = nil
+	}
+
+	return n, err
+}
+
+func (c *cachingReader) Close() error { return c.r.Close() }
@@ -0,0 +1,336 @@
+// nolint gomnd
+package httpcache_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/gonet"
+	"github.com/bingoohuang/gonet/httpcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, client *http.Client, url string, header ...string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.Nil(t, err)
+
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+
+	return resp, string(body)
+}
+
+func TestMaxAge(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("hello " + strconv.Itoa(int(n))))
+	}))
+	defer ts.Close()
+
+	client := httpcache.NewMemoryTransport(10).Client()
+
+	resp, body := get(t, client, ts.URL)
+	assert.Equal(t, "hello 1", body)
+	assert.False(t, httpcache.FromCache(resp))
+
+	resp, body = get(t, client, ts.URL)
+	assert.Equal(t, "hello 1", body)
+	assert.True(t, httpcache.FromCache(resp))
+	assert.Equal(t, "", resp.Header.Get("X-Httpcache-Stored-At"))
+
+	// no-cache of the request forces the revalidation, without validators it is refetched.
+	resp, body = get(t, client, ts.URL, "Cache-Control", "no-cache")
+	assert.Equal(t, "hello 2", body)
+	assert.False(t, httpcache.FromCache(resp))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestExpires(t *testing.T) {
+	var hits int32
+
+	now := time.Now()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(10*time.Second).UTC().Format(http.TimeFormat))
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	tr := httpcache.NewMemoryTransport(10)
+	client := tr.Client()
+
+	get(t, client, ts.URL)
+	resp, _ := get(t, client, ts.URL)
+	assert.True(t, httpcache.FromCache(resp))
+
+	tr.Now = func() time.Time { return now.Add(time.Minute) }
+	resp, _ = get(t, client, ts.URL)
+	assert.False(t, httpcache.FromCache(resp))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestRevalidate(t *testing.T) {
+	var hits, notModified int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	client := httpcache.NewMemoryTransport(10).Client()
+
+	resp, body := get(t, client, ts.URL)
+	assert.Equal(t, "hello", body)
+	assert.False(t, httpcache.FromCache(resp))
+
+	resp, body = get(t, client, ts.URL)
+	assert.Equal(t, "hello", body)
+	assert.True(t, httpcache.FromCache(resp))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+}
+
+func TestVary(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	storage := httpcache.NewMemoryStorage(10)
+	client := httpcache.NewTransport(storage).Client()
+
+	_, body := get(t, client, ts.URL, "Accept-Language", "en")
+	assert.Equal(t, "hello en", body)
+
+	resp, body := get(t, client, ts.URL, "Accept-Language", "en")
+	assert.Equal(t, "hello en", body)
+	assert.True(t, httpcache.FromCache(resp))
+
+	resp, body = get(t, client, ts.URL, "Accept-Language", "zh")
+	assert.Equal(t, "hello zh", body)
+	assert.False(t, httpcache.FromCache(resp))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// both variants are cached, by the index and the variant keys
+	for _, lang := range []string{"en", "zh"} {
+		resp, body = get(t, client, ts.URL, "Accept-Language", lang)
+		assert.Equal(t, "hello "+lang, body)
+		assert.True(t, httpcache.FromCache(resp))
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Equal(t, 3, storage.Len())
+
+	// the unsafe method invalidates all the variants
+	resp, err := client.Post(ts.URL, "text/plain", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 0, storage.Len())
+}
+
+// slowStorage slows down the Get to widen the window of the concurrent updates.
+type slowStorage struct {
+	httpcache.Storage
+}
+
+func (s slowStorage) Get(key string) ([]byte, bool) {
+	time.Sleep(time.Millisecond)
+	return s.Storage.Get(key)
+}
+
+func TestVaryConcurrent(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	client := httpcache.NewTransport(slowStorage{Storage: httpcache.NewMemoryStorage(100)}).Client()
+
+	const n = 20
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(lang string) {
+			defer wg.Done()
+			get(t, client, ts.URL, "Accept-Language", lang)
+		}(strconv.Itoa(i))
+	}
+
+	wg.Wait()
+
+	// no variant is lost from the index by the concurrent responses
+	for i := 0; i < n; i++ {
+		resp, body := get(t, client, ts.URL, "Accept-Language", strconv.Itoa(i))
+		assert.Equal(t, "hello "+strconv.Itoa(i), body)
+		assert.True(t, httpcache.FromCache(resp))
+	}
+
+	assert.Equal(t, int32(n), atomic.LoadInt32(&hits))
+}
+
+func TestNoStoreAndInvalidate(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		if r.URL.Path == "/nostore" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	storage := httpcache.NewMemoryStorage(10)
+	client := httpcache.NewTransport(storage).Client()
+
+	get(t, client, ts.URL+"/nostore")
+	assert.Equal(t, 0, storage.Len())
+
+	get(t, client, ts.URL+"/data")
+	assert.Equal(t, 1, storage.Len())
+
+	resp, err := client.Post(ts.URL+"/data", "text/plain", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 0, storage.Len())
+
+	resp, _ = get(t, client, ts.URL+"/nocached", "Cache-Control", "only-if-cached")
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+
+		if r.URL.Path == "/chunked" { // the unknown Content-Length
+			_, _ = w.Write([]byte("hello "))
+			w.(http.Flusher).Flush()
+		}
+
+		_, _ = w.Write([]byte("bingoohuang"))
+	}))
+	defer ts.Close()
+
+	storage := httpcache.NewMemoryStorage(10)
+	transport := httpcache.NewTransport(storage)
+	transport.MaxBodySize = 10
+	client := transport.Client()
+
+	for _, path := range []string{"/chunked", "/known"} {
+		_, body := get(t, client, ts.URL+path)
+		assert.Equal(t, 0, storage.Len(), path)
+
+		resp, body2 := get(t, client, ts.URL+path)
+		assert.Equal(t, body, body2)
+		assert.False(t, httpcache.FromCache(resp))
+	}
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+
+	// the body of the max size is cached
+	transport.MaxBodySize = 17
+	get(t, client, ts.URL+"/chunked")
+	resp, body := get(t, client, ts.URL+"/chunked")
+	assert.Equal(t, "hello bingoohuang", body)
+	assert.True(t, httpcache.FromCache(resp))
+}
+
+func TestMemoryStorageLRU(t *testing.T) {
+	s := httpcache.NewMemoryStorage(2)
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	s.Get("a")
+	s.Set("c", []byte("3"))
+
+	_, ok := s.Get("b")
+	assert.False(t, ok)
+
+	v, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(v))
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestDiskStorageWithReqOption(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(`{"name":"bingoo"}`))
+	}))
+	defer ts.Close()
+
+	storage, err := httpcache.NewDiskStorage(t.TempDir())
+	assert.Nil(t, err)
+
+	option := gonet.NewReqOption()
+	option.Transport = httpcache.NewTransport(storage)
+
+	for i := 0; i < 3; i++ {
+		var v struct{ Name string }
+
+		assert.Nil(t, option.RestGet(ts.URL, &v))
+		assert.Equal(t, "bingoo", v.Name)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	// a new transport of the same directory reuses the cached responses.
+	resp, body := get(t, httpcache.NewTransport(storage).Client(), ts.URL)
+	assert.True(t, httpcache.FromCache(resp))
+	assert.Equal(t, `{"name":"bingoo"}`, body)
+}
@@ -0,0 +1,141 @@
+package httpcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryStorage is the in-memory Storage which evicts the least recently used entries.
+type MemoryStorage struct {
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryEntry struct {
+	key  string
+	data []byte
+}
+
+// NewMemoryStorage creates the MemoryStorage of the max entries, zero or negative for unlimited.
+func NewMemoryStorage(maxEntries int) *MemoryStorage {
+	return &MemoryStorage{maxEntries: maxEntries, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Get returns the stored data of the key.
+func (s *MemoryStorage) Get(key string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.lru.MoveToFront(e)
+
+	return e.Value.(*memoryEntry).data, true
+}
+
+// Set stores the data of the key.
+func (s *MemoryStorage) Set(key string, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*memoryEntry).data = data
+		s.lru.MoveToFront(e)
+
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, data: data})
+
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// Delete deletes the data of the key.
+func (s *MemoryStorage) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// Len returns the number of the entries.
+func (s *MemoryStorage) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.lru.Len()
+}
+
+// DiskStorage is the Storage of the files in a directory, named by the sha256 of the keys.
+type DiskStorage struct {
+	// Dir is the directory of the files.
+	Dir string
+}
+
+// NewDiskStorage creates the DiskStorage of the directory, which is created if not exists.
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil { // nolint gomnd
+		return nil, err
+	}
+
+	return &DiskStorage{Dir: dir}, nil
+}
+
+func (s *DiskStorage) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the stored data of the key.
+func (s *DiskStorage) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(s.filename(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Set stores the data of the key, by writing a temporary file and renaming it to keep the file complete.
+func (s *DiskStorage) Set(key string, data []byte) {
+	f, err := ioutil.TempFile(s.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), s.filename(key))
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+// Delete deletes the data of the key.
+func (s *DiskStorage) Delete(key string) {
+	_ = os.Remove(s.filename(key))
+}
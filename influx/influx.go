@@ -13,7 +13,10 @@ import (
 )
 
 type Options struct {
+	// Basic is the base64 encoded basic auth, kept for compatibility, use Auth instead.
 	Basic string
+	// Auth is the authenticator of the requests.
+	Auth gonet.Authenticator
 }
 
 type OptionsFn func(options *Options)
@@ -23,12 +26,35 @@ func WithBasic(auth string) OptionsFn {
 	return func(options *Options) {
 		if auth != "" {
 			options.Basic = base64.StdEncoding.EncodeToString([]byte(auth))
+			username, password := auth, ""
+
+			if p := strings.Index(auth, ":"); p >= 0 {
+				username, password = auth[:p], auth[p+1:]
+			}
+
+			options.Auth = gonet.BasicAuth{Username: username, Password: password}
 		} else {
 			options.Basic = ""
+			options.Auth = nil
 		}
 	}
 }
 
+// WithAuth set the authenticator, like gonet.BearerAuth for the InfluxDB 2.x tokens.
+func WithAuth(auth gonet.Authenticator) OptionsFn {
+	return func(options *Options) {
+		options.Auth = auth
+	}
+}
+
+func (o *Options) apply(req *gonet.HTTPReq) {
+	if o.Auth != nil {
+		req.Auth(o.Auth)
+	} else if o.Basic != "" {
+		req.Header("Authorization", "Basic "+o.Basic)
+	}
+}
+
 // Query execute influxQl (refer to https://docs.influxdata.com/influxdb/v1.7/query_language)
 // influxDBAddr  InfluxDB的连接地址， 例如http://localhost:8086, 注意：1. 右边没有/ 2. 右边不带其它path，例如/query等。
 // usage example: influx.Query(addr, ql, influx.WithBasic("username:password"))
@@ -42,9 +68,7 @@ func Query(influxDBAddr, influxQl string, fns ...OptionsFn) (string, error) {
 	for _, fn := range fns {
 		fn(options)
 	}
-	if options.Basic != "" {
-		req.Header("Authorization", "Basic "+options.Basic)
-	}
+	options.apply(req)
 
 	req.Param("q", influxQl)
 
@@ -64,9 +88,7 @@ func Write(influxDBWriteAddr, line string, fns ...OptionsFn) (*http.Response, st
 	for _, fn := range fns {
 		fn(options)
 	}
-	if options.Basic != "" {
-		req.Header("Authorization", "Basic "+options.Basic)
-	}
+	options.apply(req)
 	req.Body([]byte(line))
 
 	rsp, err := req.SendOut()
@@ -0,0 +1,58 @@
+package gonet
+
+import (
+	"net/http"
+)
+
+// Invoker sends the request downstream and returns the response.
+type Invoker func(*http.Request) (*http.Response, error)
+
+// Interceptor intercepts the sending of the request.
+// It sees the outgoing request and the resulting response/error of next,
+// and it can mutate the request, short-circuit without calling next,
+// or replay the request by calling next more than once (see RewindBody).
+type Interceptor func(req *http.Request, next Invoker) (*http.Response, error)
+
+// Chain chains the interceptors around the invoker, the first interceptor is the outermost one.
+func Chain(invoker Invoker, interceptors ...Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+
+	return invoker
+}
+
+// Doer is the interface of the http clients, like *http.Client.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// InterceptClient is a Doer which runs the interceptors around the underlying client.
+type InterceptClient struct {
+	Client       Doer
+	Interceptors []Interceptor
+}
+
+// Do runs the interceptors around the underlying client's Do.
+func (c *InterceptClient) Do(req *http.Request) (*http.Response, error) {
+	return Chain(c.Client.Do, c.Interceptors...)(req)
+}
+
+// RewindBody rewinds the request's body by its GetBody for replaying the request.
+func RewindBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+
+	req.Body = body
+
+	return nil
+}
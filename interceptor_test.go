@@ -0,0 +1,78 @@
+package gonet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptors(t *testing.T) {
+	var bodies []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		_, _ = w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer ts.Close()
+
+	var order []string
+
+	trace := func(req *http.Request, next Invoker) (*http.Response, error) {
+		order = append(order, "trace")
+		req.Header.Set("X-Trace", "t1")
+
+		return next(req)
+	}
+
+	replay := func(req *http.Request, next Invoker) (*http.Response, error) {
+		order = append(order, "replay")
+
+		rsp, err := next(req)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = rsp.Body.Close()
+
+		if err := RewindBody(req); err != nil {
+			return nil, err
+		}
+
+		return next(req)
+	}
+
+	s, err := MustPost(ts.URL).Intercept(trace, replay).Body("bingoohuang").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", s)
+	assert.Equal(t, []string{"trace", "replay"}, order)
+	assert.Equal(t, []string{"bingoohuang", "bingoohuang"}, bodies)
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	option := NewReqOption()
+	option.Interceptors = []Interceptor{func(req *http.Request, next Invoker) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewBufferString("short " + req.URL.Path)),
+		}, nil
+	}}
+
+	s, err := option.MustGet("http://127.0.0.1:1/circuit").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "short /circuit", s)
+	assert.Len(t, option.Interceptors, 1)
+
+	c := &InterceptClient{Client: http.DefaultClient, Interceptors: option.Interceptors}
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:1/client", nil)
+	rsp, err := c.Do(req)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(ReadString(rsp.Body), "/client"))
+}
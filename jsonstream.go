@@ -0,0 +1,210 @@
+package gonet
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+)
+
+// ErrStopJSON is returned by the callback of ForEachJSON to stop the iteration early without an error.
+var ErrStopJSON = errors.New("stop json iteration") // nolint gochecknoglobals
+
+// JSONStreamMode is the mode of the JSONStream to decode the body.
+type JSONStreamMode int
+
+const (
+	// JSONStreamAuto decodes the body as the NDJSON for the NDJSON content types,
+	// otherwise as a top-level JSON array if it starts with [, or as the NDJSON if not.
+	JSONStreamAuto JSONStreamMode = iota
+	// JSONStreamNDJSON decodes the body as the NDJSON, the values can be the arrays.
+	JSONStreamNDJSON
+	// JSONStreamArray decodes the elements of the top-level JSON array of the body.
+	JSONStreamArray
+)
+
+// ndjsonMediaTypes are the media types of the newline-delimited JSON.
+// nolint gochecknoglobals
+var ndjsonMediaTypes = []string{"application/x-ndjson", "application/ndjson", "application/jsonl",
+	"application/x-jsonlines"}
+
+// JSONStream decodes the elements of the response body one by one, without reading the entire body into memory.
+// The body can be the newline-delimited JSON (NDJSON), or a top-level JSON array whose elements are yielded,
+// see JSONStreamMode.
+// The next element is read from the connection only when it is required, which keeps the backpressure.
+type JSONStream struct {
+	closer io.Closer
+	dec    *json.Decoder
+	array  bool
+	err    error
+}
+
+// JSONStream executes the request, and returns the stream of the elements of the response body.
+// The body is decoded by the mode set by StreamMode.
+// The stream should be closed after using, closing before the end closes the connection.
+func (b *HTTPReq) JSONStream() (*JSONStream, error) {
+	resp, err := b.getResponse()
+	if err != nil || resp.Body == nil {
+		closeBody(resp)
+		return nil, err
+	}
+
+	mode := b.streamMode
+	if mode == JSONStreamAuto {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if containsString(ndjsonMediaTypes, mediaType) {
+			mode = JSONStreamNDJSON
+		}
+	}
+
+	return NewJSONStreamMode(resp.Body, resp.Body, mode)
+}
+
+// NewJSONStream creates the JSONStream of the reader r by JSONStreamAuto, the closer is closed by Close if not nil.
+func NewJSONStream(r io.Reader, closer io.Closer) (*JSONStream, error) {
+	return NewJSONStreamMode(r, closer, JSONStreamAuto)
+}
+
+// NewJSONStreamMode creates the JSONStream of the reader r by the mode, the closer is closed by Close if not nil.
+func NewJSONStreamMode(r io.Reader, closer io.Closer, mode JSONStreamMode) (*JSONStream, error) {
+	br := bufio.NewReader(r)
+	s := &JSONStream{closer: closer}
+
+	if mode == JSONStreamAuto {
+		first, err := peekNonSpace(br)
+		if err != nil && err != io.EOF {
+			_ = s.Close()
+			return nil, err
+		}
+
+		if first == '[' {
+			mode = JSONStreamArray
+		}
+	}
+
+	s.dec = json.NewDecoder(br)
+
+	if mode == JSONStreamArray {
+		s.array = true
+
+		if err := s.openArray(); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// openArray reads the opening [ of the top-level array.
+func (s *JSONStream) openArray() error {
+	t, err := s.dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err == nil && t != json.Delim('[') {
+		return fmt.Errorf("json stream: expect a JSON array, but got %v", t)
+	}
+
+	return err
+}
+
+// peekNonSpace skips the leading white spaces, and peeks the first non-space byte.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return b, br.UnreadByte()
+	}
+}
+
+// Next decodes the next element into v, and returns false at the end of the stream or on the error, see Err.
+func (s *JSONStream) Next(v interface{}) bool {
+	if s.err != nil {
+		return false
+	}
+
+	if !s.dec.More() {
+		if s.array {
+			_, s.err = s.dec.Token() // the closing ]
+		}
+
+		if s.err == nil {
+			s.err = io.EOF
+		}
+
+		return false
+	}
+
+	s.err = s.dec.Decode(v)
+
+	return s.err == nil
+}
+
+// Err returns the error of the stream, nil for the normal end.
+func (s *JSONStream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+
+	return s.err
+}
+
+// Close closes the stream and the underlying body.
+func (s *JSONStream) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+
+	return s.closer.Close()
+}
+
+// ForEachJSON decodes the elements of the response body one by one into v, and calls fn after every decoding.
+// The v should be a pointer, which is reset to zero value before every decoding.
+// The iteration stops when fn returns an error, and ErrStopJSON stops without an error.
+// The connection is closed if the response body is not read to the end.
+// A *json.InvalidUnmarshalError is returned without sending the request if v is nil or not a pointer.
+func (b *HTTPReq) ForEachJSON(v interface{}, fn func() error) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	s, err := b.JSONStream()
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	elem := rv.Elem()
+	zero := reflect.Zero(elem.Type())
+
+	for {
+		elem.Set(zero)
+
+		if !s.Next(v) {
+			return s.Err()
+		}
+
+		if err := fn(); err != nil {
+			if err == ErrStopJSON {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
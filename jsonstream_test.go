@@ -0,0 +1,149 @@
+package gonet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type streamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+func TestForEachJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ndjson":
+			_, _ = fmt.Fprint(w, "{\"id\":1,\"name\":\"a\"}\n{\"id\":2}\n\n{\"id\":3}\n")
+		case "/array":
+			_, _ = fmt.Fprint(w, " \n[{\"id\":1,\"name\":\"a\"}, {\"id\":2},\n{\"id\":3}]\n")
+		case "/empty":
+		case "/bad":
+			_, _ = fmt.Fprint(w, "[{\"id\":1},{\"id\":")
+		}
+	}))
+	defer ts.Close()
+
+	for _, path := range []string{"/ndjson", "/array"} {
+		var (
+			item  streamItem
+			items []streamItem
+		)
+
+		err := MustGet(ts.URL+path).ForEachJSON(&item, func() error {
+			items = append(items, item)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []streamItem{{1, "a"}, {2, ""}, {3, ""}}, items)
+	}
+
+	var item streamItem
+
+	assert.Nil(t, MustGet(ts.URL+"/empty").ForEachJSON(&item, func() error { return nil }))
+	assert.NotNil(t, MustGet(ts.URL+"/bad").ForEachJSON(&item, func() error { return nil }))
+
+	// the nil and the non-pointer are rejected like json.Unmarshal
+	var nilItem *streamItem
+
+	for _, v := range []interface{}{nil, item, nilItem} {
+		var ie *json.InvalidUnmarshalError
+
+		err := MustGet(ts.URL+"/ndjson").ForEachJSON(v, func() error { return nil })
+		assert.True(t, errors.As(err, &ie), fmt.Sprintf("%T", v))
+	}
+}
+
+func TestForEachJSONStop(t *testing.T) {
+	closed := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(closed)
+
+		_, _ = fmt.Fprint(w, "[")
+
+		for i := 0; ; i++ {
+			if i > 0 {
+				_, _ = fmt.Fprint(w, ",")
+			}
+
+			if _, err := fmt.Fprintf(w, "{\"id\":%d,\"name\":%q}", i, strings.Repeat("x", 1024)); err != nil {
+				return // the client closed the connection
+			}
+		}
+	}))
+	defer ts.Close()
+
+	var (
+		item streamItem
+		n    int
+	)
+
+	err := MustGet(ts.URL).ForEachJSON(&item, func() error {
+		if n++; n == 10 {
+			return ErrStopJSON
+		}
+
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 9, item.ID)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection is not closed")
+	}
+}
+
+func TestForEachJSONMode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ndjson" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+
+		_, _ = fmt.Fprint(w, "[1,2]\n[3]\n")
+	}))
+	defer ts.Close()
+
+	forEach := func(req *HTTPReq) ([][]int, error) {
+		var (
+			item  []int
+			items [][]int
+		)
+
+		err := req.ForEachJSON(&item, func() error {
+			items = append(items, item)
+			return nil
+		})
+
+		return items, err
+	}
+
+	// the NDJSON of the arrays by the content type, or by the mode.
+	items, err := forEach(MustGet(ts.URL + "/ndjson"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{1, 2}, {3}}, items)
+
+	items, err = forEach(MustGet(ts.URL).StreamMode(JSONStreamNDJSON))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{1, 2}, {3}}, items)
+
+	// the auto mode takes the body starting with [ as a JSON array, whose elements are not []int.
+	_, err = forEach(MustGet(ts.URL))
+	assert.NotNil(t, err)
+
+	s, err := NewJSONStreamMode(strings.NewReader(`{"id":1}`), nil, JSONStreamArray)
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+}
@@ -3,6 +3,8 @@ package man_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -378,3 +380,212 @@ func TestKeepaliveOff(t *testing.T) {
 		assert.Equal(t, "bingoohuang", man9.Hello(man.URL(ts.URL)))
 	}
 }
+
+type Poster10 struct {
+	Hello func(man.URL) string
+}
+
+func TestInterceptors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("hello " + r.Header.Get("X-User")))
+	}))
+	defer ts.Close()
+
+	var p Poster10
+
+	man.New(&p, man.WithInterceptors(func(req *http.Request, next gonet.Invoker) (*http.Response, error) {
+		req.Header.Set("X-User", "bingoohuang")
+		return next(req)
+	}))
+
+	assert.Equal(t, "hello bingoohuang", p.Hello(man.URL(ts.URL)))
+}
+
+func TestHMACSigner(t *testing.T) {
+	verifier := &gonet.HMACVerifier{Keys: map[string][]byte{"k1": []byte("s3cret")}}
+	ts := httptest.NewServer(verifier.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	})))
+	defer ts.Close()
+
+	var p Poster10
+
+	signer := &gonet.HMACSigner{KeyID: "k1", Key: []byte("s3cret")}
+	man.New(&p, man.WithInterceptors(signer.Interceptor()))
+
+	assert.Equal(t, "hello bingoo", p.Hello(man.URL(ts.URL+"?name=bingoo")))
+}
+
+type curlLogger struct{ dumps []string }
+
+func (l *curlLogger) LogError(err error) {}
+func (l *curlLogger) Dump(dump []byte)   { l.dumps = append(l.dumps, string(dump)) }
+
+type PosterCurl struct {
+	man.T `method:"POST"`
+
+	Logger man.Logger
+
+	Post func(man.URL, map[string]string) string `dump:"curl"`
+}
+
+func TestDumpCurl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	logger := &curlLogger{}
+	p := PosterCurl{Logger: logger}
+	man.New(&p)
+
+	assert.Equal(t, "ok", p.Post(man.URL(ts.URL), map[string]string{"name": "bingoo"}))
+	assert.Equal(t, []string{"curl " + ts.URL + ` -H 'Content-Type: application/json;charset=utf-8'` +
+		` --data-binary '{"name":"bingoo"}'`}, logger.dumps)
+}
+
+type Poster11 struct {
+	man.T `method:"POST"`
+
+	Upload func(man.URL, man.UploadFile, man.Progress) string
+}
+
+func TestProgress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, data, _ := ReceiveFile(r, "file")
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	var p Poster11
+
+	man.New(&p)
+
+	var uploaded, downloaded int64
+
+	progress := gonet.ProgressFunc(func(p gonet.Progress) {
+		if p.Done && p.Upload {
+			uploaded = p.Bytes
+		} else if p.Done {
+			downloaded = p.Bytes
+		}
+	})
+
+	f, _ := os.Open("testdata/upload.txt")
+	defer f.Close()
+
+	assert.Equal(t, "bingoohuang", p.Upload(man.URL(ts.URL), man.MakeFile("file", "upload.txt", f), progress))
+	assert.True(t, uploaded > int64(len("bingoohuang")))
+	assert.Equal(t, int64(len("bingoohuang")), downloaded)
+}
+
+type Poster13 struct {
+	man.T `method:"POST" compress:"gzip"`
+
+	Echo func(man.URL, Agent) Agent
+}
+
+func TestCompress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz, _ := gzip.NewReader(r.Body)
+		data, _ := ioutil.ReadAll(gz)
+
+		var buf bytes.Buffer
+
+		enc, _ := gonet.LookupContentEncoder("zstd")
+		zw, _ := enc(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "zstd")
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	var p Poster13
+
+	man.New(&p)
+
+	assert.Equal(t, Agent{Name: "bingoo"}, p.Echo(man.URL(ts.URL), Agent{Name: "bingoo"}))
+}
+
+type Poster14 struct {
+	Hello func(man.URL) string
+}
+
+func TestProxy(t *testing.T) {
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("bingoo:s3cret"))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	var p Poster14
+
+	man.New(&p, man.WithProxy("http://bingoo:s3cret@"+proxy.Listener.Addr().String(), ""))
+	assert.Equal(t, "proxied http://example.com/hello", p.Hello("http://example.com/hello"))
+
+	var bad Poster14
+
+	man.New(&bad, man.WithProxy("ftp://proxy", ""))
+	assert.Equal(t, "", bad.Hello("http://example.com/hello"))
+}
+
+type XMLAgent struct {
+	Name string `xml:"name"`
+}
+
+type Poster12 struct {
+	man.T `method:"POST" contentType:"application/xml" accept:"application/xml"`
+
+	Echo     func(man.URL, XMLAgent) XMLAgent
+	EchoJSON func(man.URL, Agent) Agent `contentType:"application/json" accept:"application/json"`
+}
+
+func TestCodec(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Accept"))
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	var p Poster12
+
+	man.New(&p)
+
+	assert.Equal(t, XMLAgent{Name: "bingoo"}, p.Echo(man.URL(ts.URL), XMLAgent{Name: "bingoo"}))
+	assert.Equal(t, Agent{Name: "huang"}, p.EchoJSON(man.URL(ts.URL), Agent{Name: "huang"}))
+}
+
+type Poster15 struct {
+	man.T `method:"POST"`
+
+	Echo func(man.URL, *http.Client) string
+}
+
+func TestClientNotInput(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set(gonet.ContentType, "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("body:" + string(data)))
+	}))
+	defer ts.Close()
+
+	var p Poster15
+
+	man.New(&p)
+
+	assert.Equal(t, "body:", p.Echo(man.URL(ts.URL), http.DefaultClient))
+}
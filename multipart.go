@@ -0,0 +1,304 @@
+package gonet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FilePart is a file part of the multipart body to upload.
+type FilePart struct {
+	// FormName is the form field name of the part.
+	FormName string
+	// Filename is the filename of the part, the file is opened from the disk when Reader is nil.
+	Filename string
+	// ContentType is the content type of the part, detected by the Filename's extension if empty.
+	ContentType string
+	// Header is the additional headers of the part.
+	Header textproto.MIMEHeader
+	// Reader is the content of the part.
+	Reader io.Reader
+	// Size is the size of the Reader's content, detected from the Reader (like *bytes.Reader, *os.File) if zero.
+	Size int64
+}
+
+// nolint gochecknoglobals
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p FilePart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(p.FormName), quoteEscaper.Replace(p.Filename)))
+
+	if p.ContentType != "" {
+		h.Set("Content-Type", p.ContentType)
+	} else {
+		h.Set("Content-Type", DetectContentType(p.Filename))
+	}
+
+	for k, v := range p.Header {
+		h[k] = v
+	}
+
+	return h
+}
+
+// size returns the size of the part's content, false for unknown.
+func (p FilePart) size() (int64, bool) {
+	if p.Reader == nil {
+		fi, err := os.Stat(p.Filename)
+		if err != nil {
+			return 0, false
+		}
+
+		return fi.Size(), true
+	}
+
+	if p.Size > 0 {
+		return p.Size, true
+	}
+
+	switch r := p.Reader.(type) {
+	case interface{ Len() int }: // *bytes.Reader, *bytes.Buffer, *strings.Reader
+		return int64(r.Len()), true
+	case *os.File:
+		fi, err := r.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+
+		return fi.Size() - offset, true
+	}
+
+	return 0, false
+}
+
+// open opens the content of the part.
+func (p FilePart) open() (io.ReadCloser, error) {
+	if p.Reader == nil {
+		return os.Open(p.Filename)
+	}
+
+	return ioutil.NopCloser(p.Reader), nil
+}
+
+func (b *HTTPReq) postFiles() {
+	boundary := multipart.NewWriter(nil).Boundary()
+	b.Header("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	if b.multipartLength {
+		if n, ok := b.multipartBodyLength(boundary); ok {
+			b.req.ContentLength = n
+		}
+	}
+
+	rewinds, ok := b.multipartRewinds()
+	if !ok {
+		b.req.Body = b.multipartBody(boundary)
+		return
+	}
+
+	var (
+		lock sync.Mutex
+		last *pipeBody
+	)
+
+	b.req.GetBody = func() (io.ReadCloser, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		// the previous body may still be reading the parts, stop it before rewinding them.
+		if last != nil {
+			last.stop()
+		}
+
+		for _, rewind := range rewinds {
+			if err := rewind(); err != nil {
+				return nil, err
+			}
+		}
+
+		last = b.multipartBody(boundary)
+
+		return last, nil
+	}
+	b.req.Body, _ = b.req.GetBody()
+}
+
+// multipartRewinds returns the rewinding functions of the readers for replaying the multipart body,
+// false when any reader is not seekable.
+func (b *HTTPReq) multipartRewinds() ([]func() error, bool) {
+	var rewinds []func() error
+
+	for _, part := range b.files {
+		if part.Reader == nil {
+			continue
+		}
+
+		seeker, ok := part.Reader.(io.Seeker)
+		if !ok {
+			return nil, false
+		}
+
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, false
+		}
+
+		rewinds = append(rewinds, func() error {
+			_, err := seeker.Seek(offset, io.SeekStart)
+			return err
+		})
+	}
+
+	return rewinds, true
+}
+
+// multipartBody streams the multipart body of the files and the params through a pipe.
+// Any error of the opening and the copying of the parts is passed to the reader of the pipe.
+func (b *HTTPReq) multipartBody(boundary string) *pipeBody {
+	pr, pw := io.Pipe()
+	ctx := b.req.Context()
+	body := &pipeBody{PipeReader: pr, done: make(chan struct{})}
+
+	go func() {
+		defer close(body.done)
+
+		_ = pw.CloseWithError(b.writeMultipart(ctx, pw, boundary))
+	}()
+
+	return body
+}
+
+// pipeBody is the reading side of a body written by a goroutine through a pipe.
+type pipeBody struct {
+	*io.PipeReader
+	// done is closed once the writing goroutine returns.
+	done chan struct{}
+}
+
+// stop closes the pipe and waits for the writing goroutine to return.
+func (p *pipeBody) stop() {
+	_ = p.PipeReader.Close()
+	<-p.done
+}
+
+func (b *HTTPReq) writeMultipart(ctx context.Context, w io.Writer, boundary string) error {
+	bodyWriter := multipart.NewWriter(w)
+	if err := bodyWriter.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for _, part := range b.files {
+		if err := writePart(ctx, bodyWriter, part); err != nil {
+			return err
+		}
+	}
+
+	if err := b.writeFields(bodyWriter); err != nil {
+		return err
+	}
+
+	return bodyWriter.Close()
+}
+
+// writeFields writes the params as the text fields in the order of the sorted keys.
+func (b *HTTPReq) writeFields(bodyWriter *multipart.Writer) error {
+	keys := make([]string, 0, len(b.params))
+	for k := range b.params {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range b.params[k] {
+			if err := bodyWriter.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writePart(ctx context.Context, bodyWriter *multipart.Writer, part FilePart) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	partWriter, err := bodyWriter.CreatePart(part.header())
+	if err != nil {
+		return err
+	}
+
+	r, err := part.open()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	_, err = io.Copy(partWriter, &ctxReader{ctx: ctx, r: r})
+
+	return err
+}
+
+// multipartBodyLength computes the length of the multipart body, false when any part size is unknown.
+func (b *HTTPReq) multipartBodyLength(boundary string) (int64, bool) {
+	var c countWriter
+
+	bodyWriter := multipart.NewWriter(&c)
+	_ = bodyWriter.SetBoundary(boundary)
+
+	var size int64
+
+	for _, part := range b.files {
+		n, ok := part.size()
+		if !ok {
+			return 0, false
+		}
+
+		size += n
+		_, _ = bodyWriter.CreatePart(part.header())
+	}
+
+	_ = b.writeFields(bodyWriter)
+	_ = bodyWriter.Close()
+
+	return c.n + size, true
+}
+
+// countWriter counts the bytes written.
+type countWriter struct{ n int64 }
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// ctxReader is an io.Reader which stops reading once the ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
@@ -0,0 +1,109 @@
+package gonet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedPart struct {
+	Filename, ContentType, Extra, Content string
+}
+
+func multipartServer(t *testing.T, parts *[]receivedPart, contentLength *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*contentLength = r.ContentLength
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+
+			content, _ := ioutil.ReadAll(p)
+			*parts = append(*parts, receivedPart{
+				Filename:    p.FileName(),
+				ContentType: p.Header.Get("Content-Type"),
+				Extra:       p.Header.Get("X-Extra"),
+				Content:     string(content),
+			})
+		}
+
+		_, _ = w.Write([]byte("OK"))
+	}))
+}
+
+func TestPostFileReader(t *testing.T) {
+	var (
+		parts         []receivedPart
+		contentLength int64
+	)
+
+	ts := multipartServer(t, &parts, &contentLength)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "a.txt")
+	assert.Nil(t, ioutil.WriteFile(f, []byte("disk"), 0600))
+
+	s, err := MustPost(ts.URL).
+		PostFile("f1", f).
+		PostFileReader("f2", "b.bin", "application/x-b", strings.NewReader("reader")).
+		PostFilePart(FilePart{
+			FormName: "f3", Filename: "c.json", Reader: bytes.NewBufferString("{}"),
+			Header: textproto.MIMEHeader{"X-Extra": {"extra"}},
+		}).
+		MultipartContentLength(true).
+		String()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", s)
+	assert.Equal(t, []receivedPart{
+		{Filename: "a.txt", ContentType: "text/plain; charset=utf-8", Content: "disk"},
+		{Filename: "b.bin", ContentType: "application/x-b", Content: "reader"},
+		{Filename: "c.json", ContentType: "application/json", Extra: "extra", Content: "{}"},
+	}, parts)
+	assert.True(t, contentLength > 0)
+}
+
+func TestPostFileUnknownLength(t *testing.T) {
+	var (
+		parts         []receivedPart
+		contentLength int64
+	)
+
+	ts := multipartServer(t, &parts, &contentLength)
+	defer ts.Close()
+
+	r := ioutil.NopCloser(strings.NewReader("unknown")) // size unknown
+	_, err := MustPost(ts.URL).PostFileReader("f", "u.txt", "", r).MultipartContentLength(true).String()
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), contentLength)
+	assert.Equal(t, "unknown", parts[0].Content)
+}
+
+func TestPostFileError(t *testing.T) {
+	var (
+		parts         []receivedPart
+		contentLength int64
+	)
+
+	ts := multipartServer(t, &parts, &contentLength)
+	defer ts.Close()
+
+	_, err := MustPost(ts.URL).PostFile("f", filepath.Join(t.TempDir(), "missing.txt")).String()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,168 @@
+package gonet
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// TransportPool caches the http.Transports keyed by the timeouts and the TLS config,
+// so that the requests sharing the same settings reuse the pooled connections
+// instead of paying a new TCP and TLS handshake every time.
+type TransportPool struct {
+	// MaxIdleConns controls the maximum number of idle connections across all hosts of one transport.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost controls the maximum idle connections to keep per-host of one transport.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is the maximum amount of time an idle connection will remain idle before closing itself.
+	IdleConnTimeout time.Duration
+
+	transports map[transportKey]*http.Transport
+	// http2s are the HTTP/2 transports configured for the transports, to close their idle connections.
+	http2s map[transportKey]*http2.Transport
+	lock   sync.RWMutex
+}
+
+// transportKey is the key of the pooled transports.
+// The proxy is not part of the key, because it is resolved per request from the context by proxyFromContext,
+// and http.Transport keeps the idle connections apart per proxy URL by itself.
+// The ProxyConfig is, because its SOCKS5 proxy is dialed by the dialer of the transport.
+type transportKey struct {
+	connectTimeout   time.Duration
+	readWriteTimeout time.Duration
+	connLifetime     time.Duration
+	tlsConfig        *tls.Config
+	protocol         Protocol
+	proxyConfig      *ProxyConfig
+}
+
+// nolint gochecknoglobals
+var (
+	// DefaultTransportPool is the transport pool used by the ReqOptions without their own Pool.
+	DefaultTransportPool = NewTransportPool()
+)
+
+// NewTransportPool creates a TransportPool with default settings.
+func NewTransportPool() *TransportPool {
+	return &TransportPool{
+		MaxIdleConns:        100, // nolint gomnd
+		MaxIdleConnsPerHost: runtime.GOMAXPROCS(0) + 1,
+		IdleConnTimeout:     90 * time.Second, // nolint gomnd
+		transports:          make(map[transportKey]*http.Transport),
+	}
+}
+
+// Transport returns the pooled transport for the settings of the ReqOption.
+func (p *TransportPool) Transport(s *ReqOption) *http.Transport {
+	key := transportKey{
+		connectTimeout:   s.ConnectTimeout,
+		readWriteTimeout: s.ReadWriteTimeout,
+		connLifetime:     s.ConnLifetime,
+		tlsConfig:        s.TLSClientConfig,
+		protocol:         s.Protocol,
+		proxyConfig:      s.ProxyConfig,
+	}
+
+	p.lock.RLock()
+	t, ok := p.transports[key]
+	p.lock.RUnlock()
+
+	if ok {
+		return t
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if t, ok = p.transports[key]; ok {
+		return t
+	}
+
+	t = &http.Transport{
+		TLSClientConfig:     s.TLSClientConfig,
+		Proxy:               proxyFromContext,
+		DialContext:         s.dialer().DialContext,
+		MaxIdleConns:        p.MaxIdleConns,
+		MaxIdleConnsPerHost: p.MaxIdleConnsPerHost,
+		IdleConnTimeout:     p.IdleConnTimeout,
+	}
+
+	if p.transports == nil {
+		p.transports = make(map[transportKey]*http.Transport)
+	}
+
+	p.transports[key] = t
+
+	// the error is only for the transport configured already, which is impossible for the new one.
+	if t2, err := configureTransport(t, s.Protocol, s.dialer()); err == nil && t2 != nil {
+		if p.http2s == nil {
+			p.http2s = make(map[transportKey]*http2.Transport)
+		}
+
+		p.http2s[key] = t2
+	}
+
+	return t
+}
+
+// CloseIdleConnections closes the idle connections of all the pooled transports.
+func (p *TransportPool) CloseIdleConnections() {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+
+	for _, t := range p.http2s {
+		t.CloseIdleConnections()
+	}
+}
+
+// Close closes the idle connections and drops all the pooled transports.
+// The pool is still usable after Close, new transports will be created on demand.
+func (p *TransportPool) Close() {
+	p.lock.Lock()
+	transports, http2s := p.transports, p.http2s
+	p.transports = make(map[transportKey]*http.Transport)
+	p.http2s = nil
+	p.lock.Unlock()
+
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+
+	for _, t := range http2s {
+		t.CloseIdleConnections()
+	}
+}
+
+// Len returns the number of the pooled transports.
+func (p *TransportPool) Len() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(p.transports)
+}
+
+type proxyCtxKey struct{}
+
+// withProxy binds the proxy func to the ctx for proxyFromContext.
+func withProxy(ctx context.Context, proxy func(*http.Request) (*url.URL, error)) context.Context {
+	return context.WithValue(ctx, proxyCtxKey{}, proxy)
+}
+
+// proxyFromContext resolves the proxy func bound by withProxy for the pooled transports.
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	if proxy, ok := req.Context().Value(proxyCtxKey{}).(func(*http.Request) (*url.URL, error)); ok && proxy != nil {
+		return proxy(req)
+	}
+
+	return nil, nil
+}
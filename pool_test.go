@@ -0,0 +1,71 @@
+package gonet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportPool(t *testing.T) {
+	var conns int32
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("bingoohuang"))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+
+	defer ts.Close()
+
+	option := NewReqOption()
+	option.Pool = NewTransportPool()
+
+	for i := 0; i < 5; i++ {
+		s, err := option.MustGet(ts.URL).String()
+		assert.Nil(t, err)
+		assert.Equal(t, "bingoohuang", s)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	assert.Equal(t, 1, option.Pool.Len())
+
+	option.Close()
+	assert.Equal(t, 0, option.Pool.Len())
+
+	s, err := option.MustGet(ts.URL).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoohuang", s)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&conns))
+}
+
+func TestTransportPoolProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	option := NewReqOption()
+	option.Pool = NewTransportPool()
+
+	defer option.Close()
+
+	s, err := option.MustGet("http://a.b.c/x").Proxy(func(*http.Request) (*url.URL, error) {
+		return url.Parse(proxy.URL)
+	}).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "proxied http://a.b.c/x", s)
+
+	// the same pooled transport without proxy
+	_, err = option.MustGet("http://127.0.0.1:1/x").String()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, option.Pool.Len())
+}
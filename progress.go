@@ -0,0 +1,121 @@
+package gonet
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Progress is the progress of the transferring of a request body or a response body.
+type Progress struct {
+	// Upload tells the progress is of the request body uploading, otherwise the response body downloading.
+	Upload bool
+	// Bytes is the number of the bytes transferred.
+	Bytes int64
+	// Total is the total number of the bytes, -1 for unknown.
+	Total int64
+	// Rate is the average transferring rate in bytes per second.
+	Rate float64
+	// ETA is the estimated time to finish, -1 for unknown.
+	ETA time.Duration
+	// Done tells the transferring is finished.
+	Done bool
+}
+
+// ProgressReporter reports the transferring progress.
+type ProgressReporter interface {
+	// Report reports the progress.
+	Report(p Progress)
+}
+
+// ProgressFunc is the adapter to allow the use of ordinary functions as the ProgressReporter.
+type ProgressFunc func(p Progress)
+
+// Report calls f(p).
+func (f ProgressFunc) Report(p Progress) { f(p) }
+
+// ProgressInterval is the minimum interval between the progress reports, except the final one.
+const ProgressInterval = 100 * time.Millisecond
+
+// progressReader is the io.ReadCloser which reports the reading progress.
+type progressReader struct {
+	r        io.ReadCloser
+	reporter ProgressReporter
+	progress Progress
+	start    time.Time
+	last     time.Time
+}
+
+// NewProgressReader wraps the r to report the reading progress, the total is -1 for unknown.
+func NewProgressReader(r io.ReadCloser, total int64, upload bool, reporter ProgressReporter) io.ReadCloser {
+	if total < 0 {
+		total = -1
+	}
+
+	return &progressReader{
+		r:        r,
+		reporter: reporter,
+		progress: Progress{Upload: upload, Total: total, ETA: -1},
+		start:    time.Now(),
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if p.progress.Done {
+		return n, err
+	}
+
+	p.progress.Bytes += int64(n)
+	p.progress.Done = err == io.EOF
+
+	if now := time.Now(); p.progress.Done || now.Sub(p.last) >= ProgressInterval {
+		p.last = now
+		p.report(now)
+	}
+
+	return n, err
+}
+
+func (p *progressReader) report(now time.Time) {
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		p.progress.Rate = float64(p.progress.Bytes) / elapsed
+	}
+
+	switch {
+	case p.progress.Done:
+		p.progress.ETA = 0
+	case p.progress.Total >= 0 && p.progress.Rate > 0:
+		remain := float64(p.progress.Total - p.progress.Bytes)
+		p.progress.ETA = time.Duration(remain / p.progress.Rate * float64(time.Second))
+	}
+
+	p.reporter.Report(p.progress)
+}
+
+func (p *progressReader) Close() error { return p.r.Close() }
+
+// wrapUploadProgress wraps the request body, and the replaying ones by GetBody, to report the uploading progress.
+func (b *HTTPReq) wrapUploadProgress() {
+	if b.req.Body == nil || b.req.Body == http.NoBody {
+		return
+	}
+
+	total, reporter := b.req.ContentLength, b.uploadProgress
+	if total == 0 { // unknown for the non-nil body
+		total = -1
+	}
+
+	b.req.Body = NewProgressReader(b.req.Body, total, true, reporter)
+
+	if getBody := b.req.GetBody; getBody != nil {
+		b.req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			return NewProgressReader(body, total, true, reporter), nil
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package gonet
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	const size = 100 << 10
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write([]byte(strings.Repeat(string(body), size/len(body))))
+	}))
+	defer ts.Close()
+
+	var uploads, downloads []Progress
+
+	s, err := MustPost(ts.URL).Body("0123456789abcdef").
+		UploadProgress(ProgressFunc(func(p Progress) { uploads = append(uploads, p) })).
+		DownloadProgress(ProgressFunc(func(p Progress) { downloads = append(downloads, p) })).
+		String()
+	assert.Nil(t, err)
+	assert.Len(t, s, size)
+
+	last := uploads[len(uploads)-1]
+	assert.True(t, last.Upload && last.Done)
+	assert.Equal(t, int64(16), last.Bytes)
+	assert.Equal(t, int64(16), last.Total)
+
+	last = downloads[len(downloads)-1]
+	assert.True(t, !last.Upload && last.Done)
+	assert.Equal(t, int64(size), last.Bytes)
+	assert.Equal(t, int64(size), last.Total)
+	assert.Equal(t, int64(0), int64(last.ETA))
+}
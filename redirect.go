@@ -0,0 +1,111 @@
+package gonet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxRedirects is the max requests of a redirect chain by default, the same as the http.Client.
+const DefaultMaxRedirects = 10
+
+// ErrTooManyRedirects is the error wrapped when the redirects exceed the max.
+var ErrTooManyRedirects = errors.New("too many redirects") // nolint gochecknoglobals
+
+// RedirectPolicy decides whether to follow the redirect to req, like http.Client.CheckRedirect.
+// The via are the requests made already, the oldest first, and req.Response is the redirect response.
+// It returns nil to follow, http.ErrUseLastResponse to stop and return the redirect response (like to read the Location),
+// or other errors to fail the request.
+type RedirectPolicy func(req *http.Request, via []*http.Request) error
+
+// NoRedirects returns the policy which never follows the redirects, the redirect responses are returned as they are.
+func NoRedirects() RedirectPolicy {
+	return func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+}
+
+// MaxRedirects returns the policy which follows at most max redirects, and fails with ErrTooManyRedirects after that.
+func MaxRedirects(max int) RedirectPolicy {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects: %w", max, ErrTooManyRedirects)
+		}
+
+		return nil
+	}
+}
+
+// SameHostRedirects returns the policy which follows the redirects to the same host (with the port) only,
+// and at most DefaultMaxRedirects. The redirect response to another host is returned as it is.
+func SameHostRedirects() RedirectPolicy {
+	limit := MaxRedirects(DefaultMaxRedirects)
+
+	return func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host != via[0].URL.Host {
+			return http.ErrUseLastResponse
+		}
+
+		return limit(req, via)
+	}
+}
+
+// KeepAuthorization wraps the policy to keep the Authorization header of the original request on the redirects,
+// which is dropped by http.Client for the redirects to other domains.
+func KeepAuthorization(policy RedirectPolicy) RedirectPolicy {
+	return func(req *http.Request, via []*http.Request) error {
+		if err := policy(req, via); err != nil {
+			return err
+		}
+
+		if auth := via[0].Header.Get("Authorization"); auth != "" && req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		return nil
+	}
+}
+
+// Redirect is a redirect followed.
+type Redirect struct {
+	// URL is the url redirected from.
+	URL string
+	// StatusCode is the status code of the redirect response.
+	StatusCode int
+	// Location is the url redirected to.
+	Location string
+}
+
+// Redirects sets the redirect policy of the request, like NoRedirects(), SameHostRedirects() or MaxRedirects(3).
+func (b *HTTPReq) Redirects(policy RedirectPolicy) *HTTPReq {
+	b.setting.Redirect = policy
+
+	return b
+}
+
+// RedirectChain returns the redirects followed by the last sending of the request, the oldest first.
+func (b *HTTPReq) RedirectChain() []Redirect {
+	return b.redirects
+}
+
+// checkRedirect applies the redirect policy, and records the redirects followed.
+func (b *HTTPReq) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) == 1 { // the first redirect of a new sending, like a retry
+		b.redirects = nil
+	}
+
+	if policy := b.setting.Redirect; policy != nil {
+		if err := policy(req, via); err != nil {
+			return err
+		}
+	} else if len(via) >= DefaultMaxRedirects { // the same as http.Client
+		return fmt.Errorf("stopped after %d redirects: %w", DefaultMaxRedirects, ErrTooManyRedirects)
+	}
+
+	r := Redirect{URL: via[len(via)-1].URL.String(), Location: req.URL.String()}
+	if req.Response != nil {
+		r.StatusCode = req.Response.StatusCode
+	}
+
+	b.redirects = append(b.redirects, r)
+
+	return nil
+}
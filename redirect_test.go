@@ -0,0 +1,83 @@
+package gonet
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func redirectServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+			if n > 0 {
+				http.Redirect(w, r, "/hop/"+strconv.Itoa(n-1), http.StatusFound)
+				return
+			}
+
+			_, _ = w.Write([]byte("done " + r.Header.Get("Authorization")))
+		case r.URL.Path == "/away":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusMovedPermanently)
+		}
+	}))
+}
+
+func TestRedirects(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	req := MustGet(ts.URL + "/hop/2")
+	str, err := req.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "done ", str)
+	assert.Equal(t, []Redirect{
+		{URL: ts.URL + "/hop/2", StatusCode: http.StatusFound, Location: ts.URL + "/hop/1"},
+		{URL: ts.URL + "/hop/1", StatusCode: http.StatusFound, Location: ts.URL + "/hop/0"},
+	}, req.RedirectChain())
+
+	// stop at the 302 to read the Location.
+	req = MustGet(ts.URL + "/hop/2").Redirects(NoRedirects())
+	resp, err := req.SendOut()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/hop/1", resp.Header.Get("Location"))
+	assert.Len(t, req.RedirectChain(), 0)
+
+	_, err = MustGet(ts.URL + "/hop/3").Redirects(MaxRedirects(2)).String()
+	assert.True(t, errors.Is(err, ErrTooManyRedirects))
+
+	_, err = MustGet(ts.URL + "/hop/20").String()
+	assert.True(t, errors.Is(err, ErrTooManyRedirects))
+}
+
+func TestRedirectsCrossHost(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	other := redirectServer()
+	defer other.Close()
+
+	// 127.0.0.1 and localhost are different hosts.
+	target := strings.Replace(other.URL, "127.0.0.1", "localhost", 1) + "/hop/0"
+
+	req := MustGet(ts.URL + "/away?to=" + target).Redirects(SameHostRedirects()).Auth(BearerAuth{Token: "t0k3n"})
+	resp, err := req.SendOut()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, target, resp.Header.Get("Location"))
+
+	str, err := MustGet(ts.URL + "/away?to=" + target).Auth(BearerAuth{Token: "t0k3n"}).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "done ", str)
+
+	str, err = MustGet(ts.URL + "/away?to=" + target).Auth(BearerAuth{Token: "t0k3n"}).
+		Redirects(KeepAuthorization(MaxRedirects(3))).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "done Bearer t0k3n", str)
+}
@@ -2,39 +2,95 @@ package gonet
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
-	"mime/multipart"
-	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httputil"
 	"net/url"
-	"os"
 	"strings"
 	"time"
+
+	"github.com/bingoohuang/gonet/retryhttp"
 )
 
 // ReqOption ...
 type ReqOption struct {
-	ShowDebug        bool
-	EnableCookie     bool
-	Gzip             bool
-	DumpBody         bool
-	UserAgent        string
-	ConnectTimeout   time.Duration
+	ShowDebug      bool
+	EnableCookie   bool
+	Gzip           bool
+	DumpBody       bool
+	UserAgent      string
+	ConnectTimeout time.Duration
+	// ReadWriteTimeout is the idle timeout of every read or write operation on the connections.
 	ReadWriteTimeout time.Duration
-	TLSClientConfig  *tls.Config
-	CookieJar        *cookiejar.Jar
-	Proxy            func(*http.Request) (*url.URL, error)
-	Transport        http.RoundTripper
+	// ConnLifetime is the total lifetime of the connections, zero for no limit.
+	ConnLifetime    time.Duration
+	TLSClientConfig *tls.Config
+	CookieJar       *cookiejar.Jar
+	Proxy           func(*http.Request) (*url.URL, error)
+	Transport       http.RoundTripper
+	// Jar is the cookie jar of any implementation, like a PersistentJar, which has the priority over CookieJar.
+	Jar http.CookieJar
+	// ProxyConfig is the HTTP(S) or SOCKS5 proxy with the NO_PROXY rules, see ParseProxy.
+	// The Proxy has the priority over its HTTP(S) proxy, and its SOCKS5 proxy is dialed by the dialer,
+	// use ApplyProxy for the transports of the users with their own DialContext.
+	ProxyConfig *ProxyConfig
+	// Interceptors are the ordered interceptors around the sending of the requests.
+	Interceptors []Interceptor
+	// Auth is the authenticator of the requests, like BearerAuth, DigestAuth or ClientCredentials.
+	Auth Authenticator
+	// Retry is the default retry policy of the requests, no retries if nil.
+	Retry *RetryPolicy
+	// Pool is the pool of the transports used when Transport is nil, DefaultTransportPool will be used if nil.
+	Pool *TransportPool
+	// Protocol is the HTTP protocol mode of the pooled transports, ProtocolHTTP1 by default.
+	Protocol Protocol
+	// Redirect is the redirect policy, at most DefaultMaxRedirects requests of a redirect chain if nil.
+	Redirect RedirectPolicy
+	// Compress is the content coding to compress the request bodies, like gzip or zstd, no compression if empty.
+	Compress string
+	// CompressMinSize is the min size of the request bodies to compress, the smaller ones are sent as they are.
+	CompressMinSize int
+}
+
+// TransportPool returns the pool of the transports of the ReqOption.
+func (s *ReqOption) TransportPool() *TransportPool {
+	if s.Pool != nil {
+		return s.Pool
+	}
+
+	return DefaultTransportPool
+}
+
+func (s *ReqOption) dialer() DialerTimeoutBean {
+	return DialerTimeoutBean{
+		ConnTimeout:      s.ConnectTimeout,
+		ReadWriteTimeout: s.ReadWriteTimeout,
+		Lifetime:         s.ConnLifetime,
+		Proxy:            s.ProxyConfig,
+	}
+}
+
+// proxy returns the Proxy, or the one of the ProxyConfig.
+func (s *ReqOption) proxy() func(*http.Request) (*url.URL, error) {
+	if s.Proxy == nil && s.ProxyConfig != nil {
+		return s.ProxyConfig.Proxy
+	}
+
+	return s.Proxy
+}
+
+// Close closes the idle connections and drops the transports pooled by the ReqOption.
+func (s *ReqOption) Close() {
+	s.TransportPool().Close()
 }
 
 // NewCookieJar creates a cookiejar to store cookies.
@@ -61,6 +117,12 @@ func NewReqOption() *ReqOption {
 
 // Req return *HTTPReq with specific Method
 func (s *ReqOption) Req(rawURL, method string) (*HTTPReq, error) {
+	return s.ReqCtx(context.Background(), rawURL, method)
+}
+
+// ReqCtx return *HTTPReq with specific Method bound to the ctx.
+// Canceling the ctx aborts the dialing, the body uploading and the response reading.
+func (s *ReqOption) ReqCtx(ctx context.Context, rawURL, method string) (*HTTPReq, error) {
 	var resp http.Response
 
 	u, err := url.Parse(rawURL)
@@ -80,9 +142,8 @@ func (s *ReqOption) Req(rawURL, method string) (*HTTPReq, error) {
 
 	return &HTTPReq{
 		url:     rawURL,
-		req:     &req,
-		params:  map[string]string{},
-		files:   map[string]string{},
+		req:     req.WithContext(ctx),
+		params:  url.Values{},
 		setting: *s,
 		resp:    &resp,
 		body:    nil,
@@ -93,12 +154,39 @@ func (s *ReqOption) Req(rawURL, method string) (*HTTPReq, error) {
 type HTTPReq struct {
 	url     string
 	req     *http.Request
-	params  map[string]string
-	files   map[string]string
+	params  url.Values
+	files   []FilePart
 	setting ReqOption
 	resp    *http.Response
 	body    []byte
 	dump    []byte
+	// multipartLength tells to compute the Content-Length of the multipart body.
+	multipartLength bool
+
+	uploadProgress, downloadProgress ProgressReporter
+
+	// resume tells ToFile to continue the partial file.
+	resume bool
+	// checksumHash and checksum are used to verify the file downloaded by ToFile.
+	checksumHash hash.Hash
+	checksum     string
+	// redirects are the redirects followed by the last sending.
+	redirects []Redirect
+	// streamMode is the mode of JSONStream and ForEachJSON to decode the response body.
+	streamMode JSONStreamMode
+}
+
+// WithContext binds the request to ctx.
+// Canceling the ctx aborts the dialing, the body uploading and the response reading.
+func (b *HTTPReq) WithContext(ctx context.Context) *HTTPReq {
+	b.req = b.req.WithContext(ctx)
+
+	return b
+}
+
+// Context returns the request's context.
+func (b *HTTPReq) Context() context.Context {
+	return b.req.Context()
 }
 
 // BasicAuth sets the request's Authorization header
@@ -116,6 +204,14 @@ func (b *HTTPReq) CookieJar(jar *cookiejar.Jar) *HTTPReq {
 	return b
 }
 
+// Jar sets the cookie jar of any implementation and enables the cookies, like a PersistentJar.
+func (b *HTTPReq) Jar(jar http.CookieJar) *HTTPReq {
+	b.setting.EnableCookie = true
+	b.setting.Jar = jar
+
+	return b
+}
+
 // EnableCookie sets enable/disable cookiejar
 func (b *HTTPReq) EnableCookie(enable bool) *HTTPReq {
 	b.setting.EnableCookie = enable
@@ -162,6 +258,13 @@ func (b *HTTPReq) Timeout(connectTimeout, readWriteTimeout time.Duration) *HTTPR
 	return b
 }
 
+// ConnLifetime sets the total lifetime of the connections.
+func (b *HTTPReq) ConnLifetime(lifetime time.Duration) *HTTPReq {
+	b.setting.ConnLifetime = lifetime
+
+	return b
+}
+
 // TLSClientConfig sets tls connection configurations if visiting https URL.
 func (b *HTTPReq) TLSClientConfig(config *tls.Config) *HTTPReq {
 	b.setting.TLSClientConfig = config
@@ -200,6 +303,14 @@ func (b *HTTPReq) ProtocolVersion(vers string) *HTTPReq {
 	return b
 }
 
+// Protocol sets the HTTP protocol mode of the pooled transport, the user Transport is not affected.
+// ProtocolVersion only changes the protocol label of the request.
+func (b *HTTPReq) Protocol(protocol Protocol) *HTTPReq {
+	b.setting.Protocol = protocol
+
+	return b
+}
+
 // Cookie add cookie into request.
 func (b *HTTPReq) Cookie(cookie *http.Cookie) *HTTPReq {
 	b.req.Header.Add("Cookie", cookie.String())
@@ -218,26 +329,118 @@ func (b *HTTPReq) Transport(transport http.RoundTripper) *HTTPReq {
 // example:
 //
 //	func(req *http.Request) (*URL.URL, error) {
-// 		u, _ := URL.ParseRequestURI("http://127.0.0.1:8118")
-// 		return u, nil
-// 	}
+//		u, _ := URL.ParseRequestURI("http://127.0.0.1:8118")
+//		return u, nil
+//	}
 func (b *HTTPReq) Proxy(proxy func(*http.Request) (*url.URL, error)) *HTTPReq {
 	b.setting.Proxy = proxy
 
 	return b
 }
 
-// Param adds query param in to request.
+// Param adds query param in to request, same as AddParam.
 // params build query string as ?key1=value1&key2=value2...
 func (b *HTTPReq) Param(key, value string) *HTTPReq {
-	b.params[key] = value
+	return b.AddParam(key, value)
+}
+
+// AddParam adds the value to the param key, keeping the existing values.
+// The params are encoded in the order of the sorted keys, and the values in the adding order,
+// into the GET query string, the urlencoded POST/PUT/PATCH body or the multipart text fields.
+func (b *HTTPReq) AddParam(key, value string) *HTTPReq {
+	b.params.Add(key, value)
+
+	return b
+}
+
+// SetParam sets the param key to the values, replacing the existing values.
+func (b *HTTPReq) SetParam(key string, values ...string) *HTTPReq {
+	b.params[key] = values
+
+	return b
+}
+
+// DelParam deletes the values of the param key.
+func (b *HTTPReq) DelParam(key string) *HTTPReq {
+	b.params.Del(key)
+
+	return b
+}
+
+// Intercept appends the interceptors around the sending of the request.
+func (b *HTTPReq) Intercept(interceptors ...Interceptor) *HTTPReq {
+	// copy to avoid appending to the shared slice of the ReqOption
+	b.setting.Interceptors = append(append([]Interceptor(nil), b.setting.Interceptors...), interceptors...)
+
+	return b
+}
+
+// Retry retries the request at most max times by the backoff and the checkRetry policies,
+// retryhttp.DefaultBackoff and retryhttp.DefaultRetryPolicy will be used if they are nil.
+func (b *HTTPReq) Retry(max int, backoff retryhttp.Backoff, checkRetry retryhttp.CheckRetry) *HTTPReq {
+	b.setting.Retry = &RetryPolicy{Max: max, Backoff: backoff, CheckRetry: checkRetry}
+
+	return b
+}
+
+// UploadProgress reports the progress of the request body uploading.
+func (b *HTTPReq) UploadProgress(reporter ProgressReporter) *HTTPReq {
+	b.uploadProgress = reporter
+
+	return b
+}
+
+// DownloadProgress reports the progress of the response body downloading.
+func (b *HTTPReq) DownloadProgress(reporter ProgressReporter) *HTTPReq {
+	b.downloadProgress = reporter
+
+	return b
+}
+
+// Resume enables the resumable downloading of ToFile.
+// The partial file left by the previous ToFile is continued by a Range request validated with If-Range.
+func (b *HTTPReq) Resume(enable bool) *HTTPReq {
+	b.resume = enable
+
+	return b
+}
+
+// Checksum verifies the file downloaded by ToFile with the hash and the expected hex encoded sum.
+func (b *HTTPReq) Checksum(h hash.Hash, sum string) *HTTPReq {
+	b.checksumHash = h
+	b.checksum = sum
+
+	return b
+}
+
+// StreamMode sets the mode of JSONStream and ForEachJSON, JSONStreamAuto by default.
+func (b *HTTPReq) StreamMode(mode JSONStreamMode) *HTTPReq {
+	b.streamMode = mode
 
 	return b
 }
 
-// PostFile ...
+// PostFile adds a file on the disk to upload in the multipart body.
 func (b *HTTPReq) PostFile(formName, filename string) *HTTPReq {
-	b.files[formName] = filename
+	return b.PostFilePart(FilePart{FormName: formName, Filename: filename})
+}
+
+// PostFileReader adds a file from the reader to upload in the multipart body.
+func (b *HTTPReq) PostFileReader(formName, filename, contentType string, reader io.Reader) *HTTPReq {
+	return b.PostFilePart(FilePart{FormName: formName, Filename: filename, ContentType: contentType, Reader: reader})
+}
+
+// PostFilePart adds a file part to upload in the multipart body.
+func (b *HTTPReq) PostFilePart(part FilePart) *HTTPReq {
+	b.files = append(b.files, part)
+
+	return b
+}
+
+// MultipartContentLength computes the Content-Length of the multipart body when all the part sizes are known,
+// for the servers which reject the chunked uploads.
+func (b *HTTPReq) MultipartContentLength(enable bool) *HTTPReq {
+	b.multipartLength = enable
 
 	return b
 }
@@ -247,39 +450,53 @@ func (b *HTTPReq) PostFile(formName, filename string) *HTTPReq {
 func (b *HTTPReq) Body(data interface{}) *HTTPReq {
 	switch t := data.(type) {
 	case string:
-		bf := bytes.NewBufferString(t)
-		b.req.Body = ioutil.NopCloser(bf)
-		b.req.ContentLength = int64(len(t))
+		b.bytesBody([]byte(t))
 	case []byte:
-		bf := bytes.NewBuffer(t)
-		b.req.Body = ioutil.NopCloser(bf)
-		b.req.ContentLength = int64(len(t))
+		b.bytesBody(t)
 	}
 
 	return b
 }
 
+// bytesBody sets the body which is replayable by GetBody.
+func (b *HTTPReq) bytesBody(data []byte) {
+	b.req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	b.req.ContentLength = int64(len(data))
+	b.req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
 // JSONBody adds request raw body encoding by JSON.
 func (b *HTTPReq) JSONBody(obj interface{}) error {
 	if b.req.Body != nil || obj == nil {
 		return errors.New("body should not be nil")
 	}
 
-	buf := bytes.NewBuffer(nil)
-	enc := json.NewEncoder(buf)
+	return b.EncodeBody(MediaTypeJSON, obj)
+}
 
-	if err := enc.Encode(obj); err != nil {
+// EncodeBody sets the request body encoded by the codec of the media type, and the Content-Type of the codec.
+func (b *HTTPReq) EncodeBody(mediaType string, obj interface{}) error {
+	data, contentType, err := EncodeBody(mediaType, obj)
+	if err != nil {
 		return err
 	}
 
-	b.req.Body = ioutil.NopCloser(buf)
-	b.req.ContentLength = int64(buf.Len())
-	b.req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	b.bytesBody(data)
+	b.req.Header.Set("Content-Type", contentType)
 
 	return nil
 }
 
-func (b *HTTPReq) buildURL(paramBody string) {
+// Accept sets the Accept header to the media types.
+func (b *HTTPReq) Accept(mediaTypes ...string) *HTTPReq {
+	b.req.Header.Set("Accept", strings.Join(mediaTypes, ", "))
+
+	return b
+}
+
+func (b *HTTPReq) buildURL(paramBody string) error {
 	// build GET URL with query string
 	m := b.req.Method
 	if m == "GET" && paramBody != "" {
@@ -289,7 +506,7 @@ func (b *HTTPReq) buildURL(paramBody string) {
 			b.url += "?" + paramBody
 		}
 
-		return
+		return nil
 	}
 
 	// build POST/PUT/PATCH URL and body
@@ -298,50 +515,16 @@ func (b *HTTPReq) buildURL(paramBody string) {
 		if len(b.files) > 0 {
 			b.postFiles()
 
-			return
+			return nil
 		}
 
 		// with params
 		if len(paramBody) > 0 {
-			b.Header("Content-Type", "application/x-www-form-urlencoded")
-			b.Body(paramBody)
+			return b.EncodeBody(MediaTypeForm, b.params)
 		}
 	}
-}
-
-func (b *HTTPReq) postFiles() {
-	pr, pw := io.Pipe()
-	bodyWriter := multipart.NewWriter(pw)
-
-	go func() {
-		for formname, filename := range b.files {
-			fileWriter, err := bodyWriter.CreateFormFile(formname, filename)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			fh, err := os.Open(filename)
-			if err != nil {
-				log.Fatal(err)
-			}
-			// iocopy
-			_, err = io.Copy(fileWriter, fh)
-			_ = fh.Close()
-
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-
-		for k, v := range b.params {
-			_ = bodyWriter.WriteField(k, v)
-		}
 
-		_ = bodyWriter.Close()
-		_ = pw.Close()
-	}()
-	b.Header("Content-Type", bodyWriter.FormDataContentType())
-	b.req.Body = ioutil.NopCloser(pr)
+	return nil
 }
 
 func (b *HTTPReq) getResponse() (*http.Response, error) {
@@ -361,61 +544,49 @@ func (b *HTTPReq) getResponse() (*http.Response, error) {
 		return resp, nil
 	}
 
-	return resp, errors.New(resp.Status)
+	return resp, NewHTTPError(resp)
 }
 
 // SendOut ...
 func (b *HTTPReq) SendOut() (*http.Response, error) { // nolint funlen
-	var paramBody string
-
-	if len(b.params) > 0 {
-		var buf bytes.Buffer
-
-		for k, v := range b.params {
-			buf.WriteString(url.QueryEscape(k))
-			buf.WriteByte('=')
-			buf.WriteString(url.QueryEscape(v))
-			buf.WriteByte('&')
-		}
-
-		paramBody = buf.String()[0 : buf.Len()-1]
+	if err := b.buildURL(b.params.Encode()); err != nil {
+		return nil, err
 	}
 
 	var err error
 
-	b.buildURL(paramBody)
-
 	if b.req.URL, err = url.Parse(b.url); err != nil {
 		return nil, err
 	}
 
 	trans := b.setting.Transport
 	if trans == nil {
-		t := &http.Transport{TLSClientConfig: b.setting.TLSClientConfig,
-			Proxy:       b.setting.Proxy,
-			DialContext: TimeoutDialer(b.setting.ConnectTimeout, b.setting.ReadWriteTimeout),
-		}
-
-		defer t.CloseIdleConnections() // fd leak w/o this
+		trans = b.setting.TransportPool().Transport(&b.setting)
 
-		trans = t
+		if proxy := b.setting.proxy(); proxy != nil {
+			b.req = b.req.WithContext(withProxy(b.req.Context(), proxy))
+		}
 	} else if t, ok := trans.(*http.Transport); ok {
 		if t.TLSClientConfig == nil {
 			t.TLSClientConfig = b.setting.TLSClientConfig
 		}
 
 		if t.Proxy == nil {
-			t.Proxy = b.setting.Proxy
+			t.Proxy = b.setting.proxy()
 		}
 
 		if t.DialContext == nil {
-			t.DialContext = TimeoutDialer(b.setting.ConnectTimeout, b.setting.ReadWriteTimeout)
+			t.DialContext = b.setting.dialer().DialContext
 		}
 	}
 
 	var jar http.CookieJar
 
-	if b.setting.EnableCookie {
+	switch {
+	case !b.setting.EnableCookie:
+	case b.setting.Jar != nil:
+		jar = b.setting.Jar
+	default:
 		if b.setting.CookieJar == nil {
 			b.setting.CookieJar = NewCookieJar()
 		}
@@ -423,19 +594,53 @@ func (b *HTTPReq) SendOut() (*http.Response, error) { // nolint funlen
 		jar = b.setting.CookieJar
 	}
 
-	client := &http.Client{Transport: trans, Jar: jar}
+	client := &http.Client{Transport: trans, Jar: jar, CheckRedirect: b.checkRedirect}
+	b.redirects = nil
 
 	if b.setting.UserAgent != "" && b.req.Header.Get("User-Agent") == "" {
 		b.req.Header.Set("User-Agent", b.setting.UserAgent)
 	}
 
+	if b.setting.Compress != "" {
+		if err := CompressRequest(b.req, b.setting.Compress, b.setting.CompressMinSize); err != nil {
+			return nil, err
+		}
+	}
+
 	if b.setting.ShowDebug {
 		if b.dump, err = httputil.DumpRequest(b.req, b.setting.DumpBody); err != nil {
 			log.Println(err.Error())
 		}
 	}
 
-	return client.Do(b.req)
+	if b.uploadProgress != nil {
+		b.wrapUploadProgress()
+	}
+
+	interceptors := b.setting.Interceptors
+	if b.setting.Auth != nil {
+		interceptors = append([]Interceptor{b.setting.Auth.Authenticate}, interceptors...)
+	}
+
+	if b.setting.Retry != nil {
+		interceptors = append([]Interceptor{b.setting.Retry.Interceptor()}, interceptors...)
+	}
+
+	send := client.Do
+	if b.setting.Gzip {
+		send = acceptEncoding(send)
+	}
+
+	rsp, err := Chain(send, interceptors...)(b.req)
+	if err == nil && b.downloadProgress != nil {
+		rsp.Body = NewProgressReader(rsp.Body, rsp.ContentLength, false, b.downloadProgress)
+	}
+
+	if err == nil && b.setting.Gzip {
+		DecodeContent(rsp)
+	}
+
+	return rsp, err
 }
 
 // String returns the body string in response.
@@ -465,41 +670,15 @@ func (b *HTTPReq) Bytes() ([]byte, error) {
 	return b.ReadResponseBody(resp)
 }
 
-// ReadResponseBody ...
+// ReadResponseBody reads the response body, decoded by its Content-Encoding if the Gzip is enabled.
 func (b *HTTPReq) ReadResponseBody(resp *http.Response) ([]byte, error) {
-	defer resp.Body.Close()
-
-	if b.setting.Gzip && resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-
-		return ioutil.ReadAll(reader)
-	}
-
-	return ioutil.ReadAll(resp.Body)
-}
-
-// ToFile saves the body data in response to one file.
-// it calls Response inner.
-func (b *HTTPReq) ToFile(filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	resp, err := b.getResponse()
-	if err != nil || resp.Body == nil {
-		return err
+	if b.setting.Gzip {
+		DecodeContent(resp) // no-op for the responses decoded by SendOut already
 	}
 
 	defer resp.Body.Close()
 
-	_, err = io.Copy(f, resp.Body)
-
-	return err
+	return ioutil.ReadAll(resp.Body)
 }
 
 // ToJSON returns the map that marshals from the body bytes as json in response .
@@ -524,28 +703,18 @@ func (b *HTTPReq) ToXML(v interface{}) error {
 	return xml.Unmarshal(data, v)
 }
 
+// Decode decodes the body bytes in response into v by the codec of the response Content-Type.
+// it calls Response inner.
+func (b *HTTPReq) Decode(v interface{}) error {
+	data, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return DecodeBody(b.resp.Header.Get("Content-Type"), data, v)
+}
+
 // Response executes request client gets response manually.
 func (b *HTTPReq) Response() (*http.Response, error) {
 	return b.getResponse()
 }
-
-// Dialer defines dialer function alias
-type Dialer func(ctx context.Context, net, addr string) (c net.Conn, err error)
-
-// TimeoutDialer returns functions of connection dialer with timeout settings for http.Transport Dial field.
-// https://gist.github.com/c4milo/275abc6eccbfd88ad56ca7c77947883a
-// HTTP client with support for read and write timeouts which are missing in Go's standard library.
-func TimeoutDialer(cTimeout time.Duration, rwTimeout time.Duration) Dialer {
-	return func(ctx context.Context, network, addr string) (net.Conn, error) {
-		conn, err := net.DialTimeout(network, addr, cTimeout)
-		if err != nil {
-			return conn, err
-		}
-
-		if rwTimeout > 0 {
-			err = conn.SetDeadline(time.Now().Add(rwTimeout))
-		}
-
-		return conn, err
-	}
-}
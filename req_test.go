@@ -1,10 +1,16 @@
 package gonet
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestResponse(t *testing.T) {
@@ -194,7 +200,7 @@ func TestToJson(t *testing.T) {
 }
 
 func TestToFile(t *testing.T) {
-	f := "GiterLab_testfile"
+	f := filepath.Join(t.TempDir(), "GiterLab_testfile")
 	req := MustGet("http://httpbin.org/ip")
 	err := req.ToFile(f)
 
@@ -222,3 +228,101 @@ func TestHeader(t *testing.T) {
 
 	t.Log(str)
 }
+
+func TestContextCancelResponse(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+
+	defer ts.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond) // nolint gomnd
+	defer cancel()
+
+	_, err := MustGet(ts.URL).WithContext(ctx).String()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestContextCancelPostFile(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done // never read the uploading body
+	}))
+
+	defer ts.Close()
+	defer close(done)
+
+	f := filepath.Join(t.TempDir(), "big.bin")
+	if err := ioutil.WriteFile(f, make([]byte, 8<<20), 0600); err != nil { // nolint gomnd
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond) // nolint gomnd
+	defer cancel()
+
+	req, err := PostCtx(ctx, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = req.PostFile("file", f).String(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTimeoutDialerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := TimeoutDialer(time.Second, time.Second)(ctx, "tcp", "127.0.0.1:1")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(r.URL.RawQuery))
+			return
+		}
+
+		data, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	s, err := MustGet(ts.URL+"?x=0").Param("id", "1").Param("id", "2").
+		AddParam("b", "3").SetParam("a", "4", "5").AddParam("c", "6").DelParam("c").String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "x=0&a=4&a=5&b=3&id=1&id=2" {
+		t.Fatalf("unexpected query %s", s)
+	}
+
+	s, err = MustPost(ts.URL).Param("id", "1").Param("id", "2").SetParam("b", "3").String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s != "b=3&id=1&id=2" {
+		t.Fatalf("unexpected form body %s", s)
+	}
+
+	s, err = MustPost(ts.URL).PostFileReader("f", "a.txt", "", strings.NewReader("a")).
+		Param("id", "1").Param("id", "2").Param("b", "3").String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, id1, id2 := strings.Index(s, `name="b"`), strings.Index(s, `name="id"`), strings.LastIndex(s, `name="id"`)
+	if !(0 <= b && b < id1 && id1 < id2) {
+		t.Fatalf("unexpected multipart fields order %s", s)
+	}
+}
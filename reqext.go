@@ -1,6 +1,9 @@
 package gonet
 
-import "log"
+import (
+	"context"
+	"log"
+)
 
 // HTTPGet 表示一次HTTP的Get调用
 func HTTPGet(url string) ([]byte, error) {
@@ -14,7 +17,12 @@ func HTTPGet(url string) ([]byte, error) {
 
 // RestGet 发起一次HTTP GET调用，并且反序列化JSON到v代表的指针中。
 func (s *ReqOption) RestGet(url string, v interface{}) error {
-	req, err := s.Get(url)
+	return s.RestGetCtx(context.Background(), url, v)
+}
+
+// RestGetCtx 发起一次绑定ctx的HTTP GET调用，并且反序列化JSON到v代表的指针中。
+func (s *ReqOption) RestGetCtx(ctx context.Context, url string, v interface{}) error {
+	req, err := s.GetCtx(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -27,14 +35,30 @@ func RestGet(url string, v interface{}) error {
 	return NewReqOption().RestGet(url, v)
 }
 
+// RestGetCtx 发起一次绑定ctx的HTTP GET调用，并且反序列化JSON到v代表的指针中。
+func RestGetCtx(ctx context.Context, url string, v interface{}) error {
+	return NewReqOption().RestGetCtx(ctx, url, v)
+}
+
 // RestPost 表示一次HTTP的POST调用
 func RestPost(url string, req interface{}, rsp interface{}) ([]byte, error) {
 	return NewReqOption().RestPostFn(url, req, rsp, nil)
 }
 
+// RestPostCtx 表示一次绑定ctx的HTTP的POST调用
+func RestPostCtx(ctx context.Context, url string, req interface{}, rsp interface{}) ([]byte, error) {
+	return NewReqOption().RestPostFnCtx(ctx, url, req, rsp, nil)
+}
+
 // RestPostFn ...
 func (s *ReqOption) RestPostFn(url string, req interface{}, rsp interface{}, fn func(*HTTPReq)) ([]byte, error) {
-	resp, err := s.Post(url)
+	return s.RestPostFnCtx(context.Background(), url, req, rsp, fn)
+}
+
+// RestPostFnCtx is the RestPostFn bound to the ctx.
+func (s *ReqOption) RestPostFnCtx(ctx context.Context, url string,
+	req interface{}, rsp interface{}, fn func(*HTTPReq)) ([]byte, error) {
+	resp, err := s.PostCtx(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +83,16 @@ func (s *ReqOption) Get(url string) (*HTTPReq, error) {
 	return s.Req(url, "GET")
 }
 
+// GetCtx returns *HTTPReq with GET Method bound to the ctx.
+func (s *ReqOption) GetCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "GET")
+}
+
+// GetCtx returns *HTTPReq with GET Method bound to the ctx.
+func GetCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().GetCtx(ctx, url)
+}
+
 // Get returns *HTTPReq with GET Method.
 func Get(url string) (*HTTPReq, error) {
 	return NewReqOption().Get(url)
@@ -89,6 +123,16 @@ func (s *ReqOption) Post(url string) (*HTTPReq, error) {
 	return s.Req(url, "POST")
 }
 
+// PostCtx returns *HTTPReq with POST Method bound to the ctx.
+func (s *ReqOption) PostCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "POST")
+}
+
+// PostCtx returns *HTTPReq with POST Method bound to the ctx.
+func PostCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().PostCtx(ctx, url)
+}
+
 // MustPost returns *HTTPReq with POST Method.
 func MustPost(url string) *HTTPReq {
 	return NewReqOption().MustPost(url)
@@ -114,6 +158,16 @@ func (s *ReqOption) Put(url string) (*HTTPReq, error) {
 	return s.Req(url, "PUT")
 }
 
+// PutCtx returns *HTTPReq with PUT Method bound to the ctx.
+func (s *ReqOption) PutCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "PUT")
+}
+
+// PutCtx returns *HTTPReq with PUT Method bound to the ctx.
+func PutCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().PutCtx(ctx, url)
+}
+
 // MustPut returns *HTTPReq with PUT Method.
 func MustPut(url string) *HTTPReq {
 	return NewReqOption().MustPut(url)
@@ -139,6 +193,16 @@ func (s *ReqOption) Delete(url string) (*HTTPReq, error) {
 	return s.Req(url, "DELETE")
 }
 
+// DeleteCtx returns *HTTPReq with DELETE Method bound to the ctx.
+func (s *ReqOption) DeleteCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "DELETE")
+}
+
+// DeleteCtx returns *HTTPReq with DELETE Method bound to the ctx.
+func DeleteCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().DeleteCtx(ctx, url)
+}
+
 // MustDelete returns *HTTPReq with DELETE Method.
 func MustDelete(url string) *HTTPReq {
 	return NewReqOption().MustDelete(url)
@@ -164,6 +228,16 @@ func (s *ReqOption) Head(url string) (*HTTPReq, error) {
 	return s.Req(url, "HEAD")
 }
 
+// HeadCtx returns *HTTPReq with HEAD Method bound to the ctx.
+func (s *ReqOption) HeadCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "HEAD")
+}
+
+// HeadCtx returns *HTTPReq with HEAD Method bound to the ctx.
+func HeadCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().HeadCtx(ctx, url)
+}
+
 // MustHead returns *HTTPReq with Head Method.
 func MustHead(url string) *HTTPReq {
 	return NewReqOption().MustHead(url)
@@ -189,6 +263,16 @@ func (s *ReqOption) Patch(url string) (*HTTPReq, error) {
 	return s.Req(url, "PATCH")
 }
 
+// PatchCtx returns *HTTPReq with PATCH Method bound to the ctx.
+func (s *ReqOption) PatchCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return s.ReqCtx(ctx, url, "PATCH")
+}
+
+// PatchCtx returns *HTTPReq with PATCH Method bound to the ctx.
+func PatchCtx(ctx context.Context, url string) (*HTTPReq, error) {
+	return NewReqOption().PatchCtx(ctx, url)
+}
+
 // MustPatch returns *HTTPReq with Patch Method.
 func MustPatch(url string) *HTTPReq {
 	return NewReqOption().MustPatch(url)
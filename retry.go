@@ -0,0 +1,102 @@
+package gonet
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bingoohuang/gonet/retryhttp"
+)
+
+// RetryPolicy is the retry policy of the requests, which reuses the policies of the retryhttp.
+type RetryPolicy struct {
+	// Max is the maximum number of retries.
+	Max int
+	// WaitMin is the minimum time to wait, 1s by default.
+	WaitMin time.Duration
+	// WaitMax is the maximum time to wait, 30s by default.
+	WaitMax time.Duration
+	// Backoff specifies the policy for how long to wait between retries, retryhttp.DefaultBackoff by default.
+	Backoff retryhttp.Backoff
+	// CheckRetry specifies the policy for handling retries, retryhttp.DefaultRetryPolicy by default.
+	CheckRetry retryhttp.CheckRetry
+}
+
+// respReadLimit limits the size of the response body to drain for reusing the connection between retries.
+const respReadLimit = 4096
+
+// Interceptor returns the interceptor which retries the request by the policy.
+// The request body is rewound by its GetBody between the attempts,
+// and the request is not retried when its body is not replayable.
+// The attempt number is carried by the request context, see retryhttp.Attempt.
+func (p RetryPolicy) Interceptor() Interceptor {
+	waitMin, waitMax := p.WaitMin, p.WaitMax
+	if waitMin <= 0 {
+		waitMin = 1 * time.Second
+	}
+
+	if waitMax <= 0 {
+		waitMax = 30 * time.Second // nolint gomnd
+	}
+
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = retryhttp.DefaultBackoff
+	}
+
+	checkRetry := p.CheckRetry
+	if checkRetry == nil {
+		checkRetry = retryhttp.DefaultRetryPolicy
+	}
+
+	return func(req *http.Request, next Invoker) (*http.Response, error) {
+		for i := 0; ; i++ {
+			if i > 0 {
+				if err := RewindBody(req); err != nil {
+					return nil, err
+				}
+			}
+
+			rsp, err := next(req.WithContext(retryhttp.WithAttempt(req.Context(), i)))
+
+			ok, checkErr := checkRetry(req.Context(), rsp, err)
+			if !ok {
+				if checkErr != nil {
+					err = checkErr
+				}
+
+				return rsp, err
+			}
+
+			if i >= p.Max || !replayable(req) {
+				return rsp, err
+			}
+
+			if err == nil && rsp != nil {
+				drainBody(rsp.Body)
+			}
+
+			timer := time.NewTimer(backoff(waitMin, waitMax, i, rsp))
+
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// replayable tells the request can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// drainBody reads the response body for reusing the connection.
+func drainBody(body io.ReadCloser) {
+	defer body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(body, respReadLimit))
+}
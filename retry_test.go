@@ -0,0 +1,155 @@
+package gonet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flakyServer(failures int32) (*httptest.Server, *int32) {
+	var attempts int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write(body)
+	})), &attempts
+}
+
+func quickBackoff(_, _ time.Duration, _ int, _ *http.Response) time.Duration { return time.Millisecond }
+
+func TestRetryBody(t *testing.T) {
+	ts, attempts := flakyServer(2) // nolint gomnd
+	defer ts.Close()
+
+	s, err := MustPost(ts.URL).Retry(3, quickBackoff, nil).Body("bingoohuang").String()
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoohuang", s)
+	assert.Equal(t, int32(3), atomic.LoadInt32(attempts))
+}
+
+func TestRetryJSON(t *testing.T) {
+	ts, attempts := flakyServer(1)
+	defer ts.Close()
+
+	option := NewReqOption()
+	option.Retry = &RetryPolicy{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}
+
+	var rsp map[string]string
+
+	_, err := option.RestPostFn(ts.URL, map[string]string{"name": "bingoo"}, &rsp, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"name": "bingoo"}, rsp)
+	assert.Equal(t, int32(2), atomic.LoadInt32(attempts))
+}
+
+func TestRetryPostFile(t *testing.T) {
+	ts, attempts := flakyServer(1)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "upload.txt")
+	assert.Nil(t, ioutil.WriteFile(f, []byte("bingoohuang"), 0600))
+
+	s, err := MustPost(ts.URL).Retry(1, quickBackoff, nil).PostFile("file", f).String()
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(s, "bingoohuang"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(attempts))
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	ts, attempts := flakyServer(10) // nolint gomnd
+	defer ts.Close()
+
+	_, err := MustGet(ts.URL).Retry(2, quickBackoff, nil).String()
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(attempts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = MustGet(ts.URL).WithContext(ctx).Retry(2, quickBackoff, nil).String()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// slowSeeker is a slow reader which records any Seek while a Read is in progress.
+type slowSeeker struct {
+	*bytes.Reader
+	started    chan struct{}
+	once       sync.Once
+	reading    int32
+	concurrent int32
+}
+
+func (s *slowSeeker) Read(p []byte) (int, error) {
+	atomic.StoreInt32(&s.reading, 1)
+	defer atomic.StoreInt32(&s.reading, 0)
+
+	s.once.Do(func() { close(s.started) })
+	time.Sleep(10 * time.Millisecond) // nolint gomnd
+
+	return s.Reader.Read(p)
+}
+
+func (s *slowSeeker) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&s.reading) == 1 {
+		atomic.StoreInt32(&s.concurrent, 1)
+	}
+
+	return s.Reader.Seek(offset, whence)
+}
+
+func TestRetryPostFileReader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		content, _ := ioutil.ReadAll(f)
+		_, _ = w.Write(content)
+	}))
+	defer ts.Close()
+
+	reader := &slowSeeker{Reader: bytes.NewReader([]byte("bingoohuang")), started: make(chan struct{})}
+
+	var attempts int32
+
+	// fails the first attempt while the part is still being read
+	failFirst := func(req *http.Request, next Invoker) (*http.Response, error) {
+		if atomic.AddInt32(&attempts, 1) > 1 {
+			return next(req)
+		}
+
+		go func() { _, _ = io.Copy(ioutil.Discard, req.Body) }()
+		<-reader.started
+
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+
+	s, err := MustPost(ts.URL).Retry(1, quickBackoff, nil).Intercept(failFirst).
+		PostFileReader("file", "upload.txt", "", reader).String()
+	assert.Nil(t, err)
+	assert.Equal(t, "bingoohuang", s)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&reader.concurrent))
+}
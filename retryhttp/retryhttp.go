@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -375,7 +376,8 @@ func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bo
 			}
 
 			// Don't retry if the error was due to TLS cert verification failure.
-			if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
+			var unknownAuthorityError x509.UnknownAuthorityError
+			if errors.As(v.Err, &unknownAuthorityError) {
 				return false, nil
 			}
 		}
@@ -516,13 +518,60 @@ LOOP:
 	}
 
 	// By default, we close the response body and return an error without
-	// returning the response
+	// returning the response, the error keeps the last response with a bounded body.
+	return nil, newGiveUpError(req.Request, resp, err, c.RetryMax+1) // nolint gomnd
+}
+
+// GiveUpError is the error returned when the retries are exhausted.
+type GiveUpError struct {
+	Method   string
+	URL      string
+	Attempts int
+	// Response is the last response if there is, whose body is closed and replaced
+	// by at most the first 4096 bytes of the original one.
+	Response *http.Response
+	// Err is the error of the last attempt if there is.
+	Err error
+}
+
+func newGiveUpError(req *http.Request, resp *http.Response, err error, attempts int) *GiveUpError {
 	if resp != nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, respReadLimit))
 		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	return &GiveUpError{Method: req.Method, URL: req.URL.String(), Attempts: attempts, Response: resp, Err: err}
+}
+
+// Error returns the error message.
+func (e *GiveUpError) Error() string {
+	msg := fmt.Sprintf("%s %s giving up after %d attempts", e.Method, e.URL, e.Attempts)
+
+	switch {
+	case e.Err != nil:
+		msg += ": " + e.Err.Error()
+	case e.Response != nil:
+		msg += ": " + e.Response.Status
 	}
 
-	return nil, fmt.Errorf("%s %s giving up after %d attempts",
-		req.Method, req.URL, c.RetryMax+1) // nolint gomnd
+	return msg
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *GiveUpError) Unwrap() error { return e.Err }
+
+type attemptKey struct{}
+
+// WithAttempt returns the context carrying the attempt number of the request, 0 for the first attempt.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns the attempt number carried by the ctx, 0 if absent.
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
 }
 
 type next int
@@ -538,6 +587,8 @@ func (c *Client) retry(req *Request, logger Logger, i int) (n next, resp *http.R
 		return errorReturn, nil, err
 	}
 
+	req.Request = req.Request.WithContext(WithAttempt(req.Context(), i))
+
 	if c.RequestLogHook != nil {
 		c.RequestLogHook(logger, req.Request, i)
 	}
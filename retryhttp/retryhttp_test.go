@@ -171,7 +171,7 @@ func testclientDo(t *testing.T, body interface{}) {
 		var err error
 		resp, err = client.Do(req)
 		if err != nil {
-			t.Fatalf("err: %v", err)
+			t.Errorf("err: %v", err)
 		}
 	}()
 
@@ -771,3 +771,44 @@ func TestClient_BackoffCustom(t *testing.T) {
 		t.Fatalf("expected retries: %d != %d", client.RetryMax, retries)
 	}
 }
+
+func TestClient_GiveUpError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("busy"))
+	}))
+	defer ts.Close()
+
+	client := NewClient()
+	client.RetryWaitMin = 10 * time.Millisecond
+	client.RetryWaitMax = 10 * time.Millisecond
+	client.RetryMax = 1
+
+	_, err := client.Get(ts.URL)
+
+	var giveUp *GiveUpError
+	if !errors.As(err, &giveUp) {
+		t.Fatalf("expected GiveUpError, got: %#v", err)
+	}
+
+	if giveUp.Attempts != 2 || giveUp.Response == nil || giveUp.Response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected GiveUpError: %#v", giveUp)
+	}
+
+	if body, _ := ioutil.ReadAll(giveUp.Response.Body); string(body) != "busy" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// the cause of the last attempt is wrapped
+	client.HTTPClient = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	})}
+
+	if _, err = client.Get(ts.URL); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected wrapped cause, got: %#v", err)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -0,0 +1,227 @@
+package gonet
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is the event of the Server-Sent Events.
+type Event struct {
+	// ID is the last event ID.
+	ID string
+	// Event is the event type, message by default.
+	Event string
+	// Data is the data of the event, the lines are joined by \n.
+	Data string
+	// Retry is the reconnection time suggested by the server, zero if not suggested.
+	Retry time.Duration
+}
+
+// DefaultEventSourceRetry is the default reconnection time of the EventSource.
+const DefaultEventSourceRetry = 3 * time.Second
+
+// EventSource subscribes the Server-Sent Events (text/event-stream), and reconnects automatically
+// with the Last-Event-ID when the connection is lost.
+type EventSource struct {
+	// URL is the url of the event stream.
+	URL string
+	// Option is the option of the requests, NewReqOption() without the ReadWriteTimeout will be used if nil.
+	// Keep the ReadWriteTimeout longer than the heartbeat interval of the server,
+	// or the idle connection is closed and reconnected.
+	Option *ReqOption
+	// Prepare customizes every request before sending, like setting the headers.
+	Prepare func(*HTTPReq)
+	// Retry is the reconnection time, DefaultEventSourceRetry by default, updated by the server's retry field.
+	Retry time.Duration
+	// LastEventID is the ID of the last event received, sent by the Last-Event-ID header when reconnecting.
+	LastEventID string
+}
+
+// NewEventSource creates the EventSource of the url.
+func NewEventSource(url string) *EventSource {
+	return &EventSource{URL: url}
+}
+
+// Subscribe receives the events by fn until the ctx is done, or the server responds 204 No Content to stop.
+// It returns ctx.Err() for the ctx done, nil for the 204 No Content,
+// the *HTTPError for the other non-2xx responses, or the error for the non text/event-stream response,
+// and reconnects for the other errors.
+func (s *EventSource) Subscribe(ctx context.Context, fn func(Event)) error {
+	for {
+		err := s.connect(ctx, fn)
+		if err != errReconnect {
+			return err
+		}
+
+		timer := time.NewTimer(s.retry())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Events subscribes the events in the background, the events channel is closed when the subscribing is stopped,
+// and then the error channel receives the error returned by Subscribe.
+func (s *EventSource) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	events, errc := make(chan Event), make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+
+		err := s.Subscribe(ctx, func(e Event) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		})
+
+		close(events)
+		errc <- err
+	}()
+
+	return events, errc
+}
+
+func (s *EventSource) retry() time.Duration {
+	if s.Retry > 0 {
+		return s.Retry
+	}
+
+	return DefaultEventSourceRetry
+}
+
+func (s *EventSource) option() *ReqOption {
+	if s.Option != nil {
+		return s.Option
+	}
+
+	option := NewReqOption()
+	option.ReadWriteTimeout = 0
+
+	return option
+}
+
+// errReconnect tells the connection is lost and should be reconnected.
+var errReconnect = errors.New("reconnect") // nolint gochecknoglobals
+
+// connect connects the event stream and dispatches the events until the stream ends.
+func (s *EventSource) connect(ctx context.Context, fn func(Event)) error {
+	req, err := s.option().GetCtx(ctx, s.URL)
+	if err != nil {
+		return err
+	}
+
+	req.Header("Accept", "text/event-stream").Header("Cache-Control", "no-cache")
+
+	if s.LastEventID != "" {
+		req.Header("Last-Event-ID", s.LastEventID)
+	}
+
+	if s.Prepare != nil {
+		s.Prepare(req)
+	}
+
+	resp, err := req.Response()
+
+	switch {
+	case ctx.Err() != nil:
+		closeBody(resp)
+		return ctx.Err()
+	case err != nil && resp != nil: // the non-2xx responses are not reconnected
+		closeBody(resp)
+		return err
+	case err != nil:
+		return errReconnect
+	case resp.StatusCode == http.StatusNoContent:
+		closeBody(resp)
+		return nil
+	}
+
+	defer resp.Body.Close()
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return fmt.Errorf("unexpected Content-Type %q of the event stream", resp.Header.Get("Content-Type"))
+	}
+
+	s.readEvents(resp.Body, fn)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return errReconnect
+}
+
+// readEvents parses the event stream, and dispatches the events to fn until the reading fails.
+func (s *EventSource) readEvents(r io.Reader, fn func(Event)) {
+	br := bufio.NewReader(r)
+
+	var (
+		e    Event
+		data strings.Builder
+		// id is the last event ID buffer, which is kept for the following events.
+		id = s.LastEventID
+	)
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return // the incomplete event is discarded
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" { // dispatch the event
+			s.LastEventID = id
+
+			if data.Len() > 0 {
+				e.ID, e.Data = id, strings.TrimSuffix(data.String(), "\n")
+				if e.Event == "" {
+					e.Event = "message"
+				}
+
+				fn(e)
+			}
+
+			e = Event{}
+			data.Reset()
+
+			continue
+		}
+
+		field, value := line, ""
+		if p := strings.IndexByte(line, ':'); p >= 0 {
+			field, value = line[:p], strings.TrimPrefix(line[p+1:], " ")
+		}
+
+		switch field {
+		case "": // comment
+		case "event":
+			e.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				e.Retry = time.Duration(ms) * time.Millisecond
+				s.Retry = e.Retry
+			}
+		}
+	}
+}
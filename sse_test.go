@@ -0,0 +1,96 @@
+package gonet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSource(t *testing.T) {
+	var (
+		lock         sync.Mutex
+		lastEventIDs []string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastEventIDs)
+		lock.Unlock()
+
+		if n == 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		if n == 1 {
+			_, _ = fmt.Fprint(w, ": comment\nretry: 10\n\nid: 1\ndata: hello\ndata: world\n\n"+
+				"event: update\r\nid: 2\r\ndata:{\"a\":1}\r\n\r\ndata: incomplete\n")
+		} else {
+			_, _ = fmt.Fprint(w, "id: 3\ndata: again\n\n")
+		}
+	}))
+	defer ts.Close()
+
+	var events []Event
+
+	s := NewEventSource(ts.URL)
+	err := s.Subscribe(context.Background(), func(e Event) { events = append(events, e) })
+	assert.Nil(t, err)
+	assert.Equal(t, []Event{
+		{ID: "1", Event: "message", Data: "hello\nworld"},
+		{ID: "2", Event: "update", Data: `{"a":1}`},
+		{ID: "3", Event: "message", Data: "again"},
+	}, events)
+	assert.Equal(t, []string{"", "2", "3"}, lastEventIDs)
+	assert.Equal(t, 10*time.Millisecond, s.Retry)
+}
+
+func TestEventSourceCancel(t *testing.T) {
+	done := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := NewEventSource(ts.URL).Events(ctx)
+
+	assert.Equal(t, "first", (<-events).Data)
+	cancel()
+
+	for range events {
+	}
+
+	assert.Equal(t, context.Canceled, <-errc)
+}
+
+func TestEventSourceNon2xx(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	err := NewEventSource(ts.URL).Subscribe(context.Background(), func(Event) {})
+
+	he, ok := err.(*HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, he.StatusCode)
+}
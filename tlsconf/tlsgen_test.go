@@ -16,8 +16,7 @@ import (
 )
 
 func TestTLSGenRootFiles(t *testing.T) {
-	assert.Nil(t, os.MkdirAll("v0", 0777))
-	assert.Nil(t, TLSGenRootFiles("v0", "root.key", "root.pem"))
+	assert.Nil(t, TLSGenRootFiles(t.TempDir(), "root.key", "root.pem"))
 }
 
 func TestTlsCertsGenv1(t *testing.T) {
@@ -0,0 +1,254 @@
+package gonet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PartSuffix is the suffix of the partial file of the resumable downloading.
+	PartSuffix = ".part"
+	// validatorSuffix is the suffix of the file storing the If-Range validator of the partial file.
+	validatorSuffix = ".validator"
+	// maxCreateTempTries is the max tries to create the temporary file of a random name.
+	maxCreateTempTries = 10000
+)
+
+// ToFile saves the body data in response to one file.
+// it calls Response inner.
+// The body is written to a temporary file which is renamed to the filename atomically at last,
+// the filename is untouched if the response is not 2xx.
+func (b *HTTPReq) ToFile(filename string) error {
+	if b.resume {
+		return b.resumeToFile(filename)
+	}
+
+	resp, err := b.getResponse()
+	if err != nil || resp.Body == nil {
+		closeBody(resp)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	f, err := createTemp(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := b.writeFile(f, resp.Body); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := b.verifyChecksum(f.Name()); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// createTemp creates the temporary file like filename.123456.part with the mode which os.Create would give,
+// that is the mode of the existing filename, or 0666 before the umask.
+func createTemp(filename string) (*os.File, error) {
+	fi, statErr := os.Stat(filename)
+
+	for i := 0; ; i++ {
+		name := filename + "." + strconv.FormatUint(uint64(rand.Uint32()), 10) + PartSuffix // nolint gosec
+
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666) // nolint gomnd
+		if os.IsExist(err) && i < maxCreateTempTries {
+			continue
+		}
+
+		if err != nil || statErr != nil {
+			return f, err
+		}
+
+		// the mode of the existing file is kept regardless of the umask, like os.Create
+		if err := f.Chmod(fi.Mode().Perm()); err != nil {
+			_ = f.Close()
+			_ = os.Remove(name)
+
+			return nil, err
+		}
+
+		return f, nil
+	}
+}
+
+// resumeToFile continues the partial file by a Range request validated with If-Range.
+// A 206 response is appended, and a 200 response rewrites the partial file fully.
+func (b *HTTPReq) resumeToFile(filename string) error {
+	part := filename + PartSuffix
+	validatorFile := part + validatorSuffix
+	offset := b.prepareRange(part, validatorFile)
+
+	// the byte offsets of the ranges are of the identity encoding
+	b.req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := b.getResponse()
+
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable &&
+		offset > 0 && rangeTotal(resp.Header.Get("Content-Range")) == offset:
+		closeBody(resp) // the partial file is already complete
+	case err != nil || resp.Body == nil:
+		closeBody(resp)
+		return err
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		defer resp.Body.Close()
+
+		if start := rangeStart(resp.Header.Get("Content-Range")); start != offset {
+			return fmt.Errorf("unexpected Content-Range %q for the partial file size %d",
+				resp.Header.Get("Content-Range"), offset)
+		}
+
+		if err := b.appendFile(part, resp.Body); err != nil {
+			return err
+		}
+	default:
+		defer resp.Body.Close()
+
+		if err := b.rewriteFile(part, validatorFile, resp); err != nil {
+			return err
+		}
+	}
+
+	if err := b.verifyChecksum(part); err != nil {
+		_ = os.Remove(part)
+		_ = os.Remove(validatorFile)
+
+		return err
+	}
+
+	_ = os.Remove(validatorFile)
+
+	return os.Rename(part, filename)
+}
+
+// prepareRange sets the Range and If-Range headers when the partial file and its validator exist,
+// and returns the offset to continue from.
+func (b *HTTPReq) prepareRange(part, validatorFile string) int64 {
+	fi, err := os.Stat(part)
+	if err != nil || fi.Size() == 0 {
+		return 0
+	}
+
+	validator, err := ioutil.ReadFile(validatorFile)
+	if err != nil || len(validator) == 0 {
+		return 0
+	}
+
+	b.req.Header.Set("Range", fmt.Sprintf("bytes=%d-", fi.Size()))
+	b.req.Header.Set("If-Range", string(validator))
+
+	return fi.Size()
+}
+
+func (b *HTTPReq) appendFile(part string, body io.Reader) error {
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	return b.writeFile(f, body)
+}
+
+func (b *HTTPReq) rewriteFile(part, validatorFile string, resp *http.Response) error {
+	_ = os.Remove(validatorFile)
+
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	// a strong validator is required by If-Range
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	if validator != "" {
+		if err := ioutil.WriteFile(validatorFile, []byte(validator), 0600); err != nil { // nolint gomnd
+			_ = f.Close()
+			return err
+		}
+	}
+
+	return b.writeFile(f, resp.Body)
+}
+
+// writeFile copies the body to the file, and closes the file.
+func (b *HTTPReq) writeFile(f *os.File, body io.Reader) error {
+	_, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// verifyChecksum verifies the checksum of the file if required.
+func (b *HTTPReq) verifyChecksum(filename string) error {
+	if b.checksumHash == nil {
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	b.checksumHash.Reset()
+
+	if _, err := io.Copy(b.checksumHash, f); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(b.checksumHash.Sum(nil)); !strings.EqualFold(sum, b.checksum) {
+		return fmt.Errorf("checksum mismatched, expected %s, got %s", b.checksum, sum)
+	}
+
+	return nil
+}
+
+// rangeStart parses the first byte position of the Content-Range like "bytes 100-199/200", -1 for invalid.
+func rangeStart(contentRange string) int64 {
+	r := strings.TrimPrefix(contentRange, "bytes ")
+	if p := strings.IndexByte(r, '-'); p > 0 {
+		if start, err := strconv.ParseInt(r[:p], 10, 64); err == nil {
+			return start
+		}
+	}
+
+	return -1
+}
+
+// rangeTotal parses the complete length of the Content-Range like "bytes */200", -1 for unknown.
+func rangeTotal(contentRange string) int64 {
+	if p := strings.LastIndexByte(contentRange, '/'); p >= 0 {
+		if total, err := strconv.ParseInt(contentRange[p+1:], 10, 64); err == nil {
+			return total
+		}
+	}
+
+	return -1
+}
+
+// closeBody closes the body of the response if there is.
+func closeBody(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+}
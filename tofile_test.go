@@ -0,0 +1,169 @@
+package gonet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fileContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func fileServer(ranges *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		*ranges = append(*ranges, r.Header.Get("Range"))
+
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(fileContent))
+	}))
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestToFileNon2xx(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, ioutil.WriteFile(f, []byte("old"), 0600))
+
+	assert.NotNil(t, MustGet(ts.URL+"/missing").ToFile(f))
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, "old", string(data))
+}
+
+func TestToFileMode(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	dir := t.TempDir()
+
+	created, err := os.Create(filepath.Join(dir, "created.txt"))
+	assert.Nil(t, err)
+	_ = created.Close()
+
+	// the mode os.Create gives
+	f := filepath.Join(dir, "file.txt")
+	assert.Nil(t, MustGet(ts.URL).ToFile(f))
+	assert.Equal(t, fileMode(t, created.Name()), fileMode(t, f))
+
+	// the mode of the existing file is kept
+	assert.Nil(t, os.Chmod(f, 0640))
+	assert.Nil(t, MustGet(ts.URL).ToFile(f))
+	assert.Equal(t, os.FileMode(0640), fileMode(t, f))
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, fileContent, string(data))
+}
+
+func fileMode(t *testing.T, name string) os.FileMode {
+	fi, err := os.Stat(name)
+	assert.Nil(t, err)
+
+	return fi.Mode().Perm()
+}
+
+func TestToFileResume(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix, []byte(fileContent[:10]), 0600))
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix+validatorSuffix, []byte(`"v1"`), 0600))
+
+	err := MustGet(ts.URL).Resume(true).Checksum(sha256.New(), sha256Hex(fileContent)).ToFile(f)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bytes=10-"}, ranges)
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, fileContent, string(data))
+
+	_, err = os.Stat(f + PartSuffix)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(f + PartSuffix + validatorSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestToFileResumeChanged(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix, []byte("stale"), 0600))
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix+validatorSuffix, []byte(`"v0"`), 0600))
+
+	assert.Nil(t, MustGet(ts.URL).Resume(true).ToFile(f))
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, fileContent, string(data))
+}
+
+func TestToFileResumeComplete(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	f := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix, []byte(fileContent), 0600))
+	assert.Nil(t, ioutil.WriteFile(f+PartSuffix+validatorSuffix, []byte(`"v1"`), 0600))
+
+	assert.Nil(t, MustGet(ts.URL).Resume(true).ToFile(f))
+
+	data, _ := ioutil.ReadFile(f)
+	assert.Equal(t, fileContent, string(data))
+}
+
+func TestToFileChecksumMismatch(t *testing.T) {
+	var ranges []string
+
+	ts := fileServer(&ranges)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	f := filepath.Join(dir, "file.txt")
+
+	assert.NotNil(t, MustGet(ts.URL).Checksum(sha256.New(), sha256Hex("other")).ToFile(f))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+
+	// the interrupted resumable downloading keeps the partial file and its validator
+	assert.Nil(t, MustGet(ts.URL).Resume(true).ToFile(f))
+	assert.True(t, bytes.Equal(mustRead(t, f), []byte(fileContent)))
+}
+
+func mustRead(t *testing.T, f string) []byte {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
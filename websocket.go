@@ -0,0 +1,709 @@
+package gonet
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // nolint gosec
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// The message types of the WebSocket, defined by RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// The close codes of the WebSocket, defined by RFC 6455.
+const (
+	CloseNormalClosure = 1000
+	CloseGoingAway     = 1001
+	CloseNoStatus      = 1005
+)
+
+// DefaultWebSocketReadLimit is the default maximum size of the messages read.
+const DefaultWebSocketReadLimit = 32 << 20
+
+// webSocketGUID is the GUID to compute the Sec-WebSocket-Accept.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWebSocketClosed is returned when writing to the closed WebSocket.
+var ErrWebSocketClosed = errors.New("websocket closed") // nolint gochecknoglobals
+
+// CloseError is the error of the close frame received.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+// Error returns the error message.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed %d %s", e.Code, e.Text)
+}
+
+// WebSocket is the connection of the WebSocket.
+// The reading methods should not be called concurrently, neither the Close,
+// the writing methods are safe to be called concurrently.
+type WebSocket struct {
+	// ReadLimit is the maximum size of the messages, DefaultWebSocketReadLimit if zero.
+	ReadLimit int64
+
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // the client masks the frames
+
+	writeLock sync.Mutex
+	// closeSent and closeReceived tell the close frames are sent or received.
+	closeSent     bool
+	closeReceived *CloseError
+
+	pongHandler func(data []byte)
+	// lastPong is the unix nano time of the last pong received.
+	lastPong int64
+
+	// closed is closed by Close to stop the keepalive.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// DialWebSocket dials the ws:// or wss:// url by the default option without the idle timeout.
+func DialWebSocket(ctx context.Context, rawURL string, header http.Header) (*WebSocket, *http.Response, error) {
+	option := NewReqOption()
+	option.ReadWriteTimeout = 0
+
+	return option.DialWebSocket(ctx, rawURL, header)
+}
+
+// DialWebSocket dials the ws:// or wss:// url, and performs the RFC 6455 upgrade with the headers.
+// It uses the TLSClientConfig, Proxy, UserAgent and the timeouts of the dialer of the option.
+// The ReadWriteTimeout of the option is the idle timeout of the WebSocket connection,
+// set it longer than the interval of the keepalive pings, or zero to disable.
+// The response is returned for the failed upgrade when there is, with the *HTTPError.
+func (s *ReqOption) DialWebSocket(ctx context.Context, rawURL string,
+	header http.Header) (*WebSocket, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, nil, fmt.Errorf("unsupported websocket scheme %s", u.Scheme)
+	}
+
+	conn, err := s.dialWebSocket(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the ctx is only used to cancel the upgrade.
+	stop, stopped := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	ws, resp, err := s.upgradeWebSocket(conn, u, header)
+
+	// the watcher is waited to exit, lest it sets the deadline after the one cleared below.
+	close(stop)
+	<-stopped
+
+	if err != nil {
+		_ = conn.Close()
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		return nil, resp, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return ws, resp, nil
+}
+
+// dialWebSocket dials the connection to the host of u, through the proxy if there is, and does the TLS handshake.
+// The proxy is resolved like the transports do, by the Proxy or the ProxyConfig with its NO_PROXY rules,
+// the HTTP(S) proxies are tunneled through by CONNECT, and the SOCKS5 ones are dialed through.
+func (s *ReqOption) dialWebSocket(ctx context.Context, u *url.URL) (net.Conn, error) {
+	addr := hostPort(u)
+	dialer := s.dialer()
+
+	var proxyURL *url.URL
+
+	if p := s.proxy(); p != nil {
+		var err error
+		if proxyURL, err = p(&http.Request{URL: u, Header: make(http.Header)}); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	switch {
+	case proxyURL == nil: // the SOCKS5 proxy of the ProxyConfig is dialed through by the dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	case proxyURL.Scheme == "http" || proxyURL.Scheme == "https":
+		conn, err = s.dialConnectProxy(ctx, dialer, proxyURL, addr)
+	default:
+		var c *ProxyConfig
+		if c, err = ParseProxy(proxyURL.String(), ""); err == nil {
+			conn, err = c.DialContext(ctx, "tcp", addr, dialer.DialContext)
+		}
+	}
+
+	if err != nil || u.Scheme != "https" {
+		return conn, err
+	}
+
+	return s.tlsClient(ctx, conn, u.Hostname())
+}
+
+// tlsClient does the TLS handshake over the conn by the TLSClientConfig, and closes the conn if failed.
+func (s *ReqOption) tlsClient(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+	config := &tls.Config{} // nolint gosec
+	if s.TLSClientConfig != nil {
+		config = s.TLSClientConfig.Clone()
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// hostPort returns the host:port of u, with the default port of the scheme.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// dialConnectProxy dials the addr through the HTTP(S) proxy by the CONNECT method,
+// the https proxy is connected by TLS with the TLSClientConfig.
+func (s *ReqOption) dialConnectProxy(ctx context.Context, dialer DialerTimeoutBean,
+	proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL.Scheme == "https" {
+		if conn, err = s.tlsClient(ctx, conn, proxyURL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		req.SetBasicAuth(u.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// the body is not closed, which may block reading until EOF for the tunnel
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: unexpected data after the response", addr)
+	}
+
+	return conn, nil
+}
+
+// upgradeWebSocket performs the upgrade handshake over the conn.
+func (s *ReqOption) upgradeWebSocket(conn net.Conn, u *url.URL, header http.Header) (*WebSocket, *http.Response, error) {
+	key, err := newWebSocketKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if s.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		e := NewHTTPError(resp)
+		_ = resp.Body.Close()
+
+		return nil, resp, e
+	}
+
+	if !headerContains(resp.Header, "Upgrade", "websocket") || !headerContains(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, resp, errors.New("websocket: bad handshake")
+	}
+
+	return &WebSocket{conn: conn, br: br, client: true, closed: make(chan struct{})}, resp, nil
+}
+
+// UpgradeWebSocket upgrades the server side HTTP connection to the WebSocket, with the extra response header.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, header http.Header) (*WebSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Upgrade", "websocket") ||
+		!headerContains(r.Header, "Connection", "upgrade") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "websocket: bad handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: bad handshake")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: hijacking unsupported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: hijacking unsupported")
+	}
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n")
+
+	for k, vs := range header {
+		for _, v := range vs {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+
+	b.WriteString("\r\n")
+
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &WebSocket{conn: conn, br: brw.Reader, closed: make(chan struct{})}, nil
+}
+
+func newWebSocketKey() (string, error) {
+	p := make([]byte, 16) // nolint gomnd
+	if _, err := io.ReadFull(rand.Reader, p); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(p), nil
+}
+
+func webSocketAccept(key string) string {
+	h := sha1.New() // nolint gosec
+	h.Write([]byte(key + webSocketGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains tells the comma separated header contains the token case-insensitively.
+func headerContains(header http.Header, name, token string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Conn returns the underlying connection.
+func (c *WebSocket) Conn() net.Conn { return c.conn }
+
+// SetPongHandler sets the handler of the pong frames received by ReadMessage.
+func (c *WebSocket) SetPongHandler(h func(data []byte)) { c.pongHandler = h }
+
+// ReadMessage reads the next text or binary message, the control frames are handled inside:
+// the pings are replied with the pongs, the pongs are passed to the pong handler,
+// and the close frame is replied and returned as the *CloseError.
+func (c *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	if c.closeReceived != nil {
+		return 0, nil, c.closeReceived
+	}
+
+	limit := c.ReadLimit
+	if limit <= 0 {
+		limit = DefaultWebSocketReadLimit
+	}
+
+	for {
+		fin, opcode, payload, err := c.readFrame(limit - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, payload); err != nil && err != ErrWebSocketClosed {
+				return 0, nil, err
+			}
+
+			continue
+		case PongMessage:
+			atomic.StoreInt64(&c.lastPong, time.Now().UnixNano())
+
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+
+			continue
+		case CloseMessage:
+			return 0, nil, c.receiveClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, errors.New("websocket: unexpected new message in the fragmented message")
+			}
+
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, errors.New("websocket: unexpected continuation frame")
+			}
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+		}
+
+		data = append(data, payload...)
+
+		if fin {
+			return messageType, data, nil
+		}
+	}
+}
+
+// receiveClose records the close frame received, and replies the close frame if not sent yet.
+func (c *WebSocket) receiveClose(payload []byte) error {
+	e := &CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 { // nolint gomnd
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Text = string(payload[2:])
+	}
+
+	c.closeReceived = e
+
+	reply := payload
+	if len(reply) > 2 { // nolint gomnd
+		reply = reply[:2]
+	}
+
+	if err := c.writeFrame(CloseMessage, reply); err != nil && err != ErrWebSocketClosed {
+		return err
+	}
+
+	return e
+}
+
+// readFrame reads a frame whose payload is not larger than the limit.
+func (c *WebSocket) readFrame(limit int64) (fin bool, opcode int, payload []byte, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, opcode = h[0]&0x80 != 0, int(h[0]&0x0f)
+	masked, n := h[1]&0x80 != 0, int64(h[1]&0x7f)
+
+	switch n {
+	case 126: // nolint gomnd
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127: // nolint gomnd
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if opcode >= CloseMessage && (n > 125 || !fin) { // nolint gomnd
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+
+	if n < 0 || n > limit {
+		return false, 0, nil, fmt.Errorf("websocket: message exceeds the read limit")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		maskBytes(mask, payload)
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func maskBytes(mask [4]byte, p []byte) {
+	for i := range p {
+		p[i] ^= mask[i%4]
+	}
+}
+
+// WriteMessage writes the text or binary message.
+func (c *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+
+	return c.writeFrame(messageType, data)
+}
+
+// WriteText writes the text message.
+func (c *WebSocket) WriteText(text string) error { return c.writeFrame(TextMessage, []byte(text)) }
+
+// Ping writes the ping frame.
+func (c *WebSocket) Ping(data []byte) error { return c.writeFrame(PingMessage, data) }
+
+// writeFrame writes the frame which is masked by the client.
+func (c *WebSocket) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	frame := make([]byte, 0, 14+len(payload)) // nolint gomnd
+	frame = append(frame, 0x80|byte(opcode))
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch n := len(payload); {
+	case n <= 125: // nolint gomnd
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n)) // nolint gomnd
+	default:
+		frame = append(frame, maskBit|127) // nolint gomnd
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(mask, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	_, err := c.conn.Write(frame)
+
+	return err
+}
+
+// KeepAlive pings every interval in the background until the WebSocket is closed,
+// and closes the connection when no pong is received in the timeout after a ping.
+// The pongs are received by ReadMessage, so keep reading the messages.
+func (c *WebSocket) KeepAlive(interval, timeout time.Duration) {
+	atomic.StoreInt64(&c.lastPong, time.Now().UnixNano())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-ticker.C:
+			}
+
+			pingAt := time.Now()
+			if err := c.Ping(nil); err != nil {
+				return
+			}
+
+			timer := time.NewTimer(timeout)
+
+			select {
+			case <-c.closed:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if atomic.LoadInt64(&c.lastPong) < pingAt.UnixNano() {
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// CloseWaitTimeout is the timeout to wait for the close frame of the peer in Close.
+const CloseWaitTimeout = 5 * time.Second
+
+// maxCloseText is the maximum size of the close text, the payload of the control frames is up to 125 bytes.
+const maxCloseText = 123
+
+// Close performs the close handshake with the code and the text, and closes the connection.
+// It sends the close frame if not sent yet, and waits for the close frame of the peer in CloseWaitTimeout,
+// the data messages received in the waiting are discarded.
+// The text is truncated at the character boundary to 123 bytes at most.
+func (c *WebSocket) Close(code int, text string) error {
+	c.closeOnce.Do(func() { close(c.closed) })
+
+	if len(text) > maxCloseText {
+		n := maxCloseText
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+
+		text = text[:n]
+	}
+
+	payload := make([]byte, 2, 2+len(text)) // nolint gomnd
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+
+	err := c.writeFrame(CloseMessage, payload)
+	if err == ErrWebSocketClosed {
+		err = nil
+	}
+
+	if err == nil && c.closeReceived == nil {
+		_ = c.conn.SetReadDeadline(time.Now().Add(CloseWaitTimeout))
+
+		for c.closeReceived == nil {
+			if _, _, err := c.ReadMessage(); err != nil {
+				break
+			}
+		}
+	}
+
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
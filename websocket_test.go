@@ -0,0 +1,256 @@
+package gonet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoWebSocket echoes the messages until the close frame received.
+func echoWebSocket(t *testing.T, closed chan<- error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r, http.Header{"X-Token": {r.Header.Get("X-Token")}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		defer ws.Conn().Close()
+
+		for {
+			typ, data, err := ws.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+
+			if err := ws.WriteMessage(typ, data); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}
+}
+
+func testWebSocket(t *testing.T, option *ReqOption, rawURL string, closed <-chan error) {
+	ws, resp, err := option.DialWebSocket(context.Background(), rawURL, http.Header{"X-Token": {"abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "abc", resp.Header.Get("X-Token"))
+
+	pong := make(chan string, 1)
+	ws.SetPongHandler(func(data []byte) { pong <- string(data) })
+
+	assert.Nil(t, ws.WriteText("hello"))
+	assert.Nil(t, ws.Ping([]byte("ping")))
+
+	big := strings.Repeat("x", 70000)
+	assert.Nil(t, ws.WriteMessage(BinaryMessage, []byte(big)))
+
+	typ, data, err := ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "hello", string(data))
+
+	typ, data, err = ws.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, big, string(data))
+	assert.Equal(t, "ping", <-pong)
+
+	assert.Nil(t, ws.Close(CloseNormalClosure, "bye"))
+
+	var ce *CloseError
+
+	assert.True(t, errors.As(<-closed, &ce))
+	assert.Equal(t, CloseNormalClosure, ce.Code)
+	assert.Equal(t, "bye", ce.Text)
+	assert.Equal(t, ErrWebSocketClosed, ws.WriteText("again"))
+}
+
+func TestWebSocket(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(echoWebSocket(t, closed))
+
+	defer ts.Close()
+
+	testWebSocket(t, NewReqOption(), "ws"+strings.TrimPrefix(ts.URL, "http"), closed)
+}
+
+func TestWebSocketCloseLongText(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(echoWebSocket(t, closed))
+
+	defer ts.Close()
+
+	ws, _, err := DialWebSocket(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, ws.Close(CloseNormalClosure, strings.Repeat("再见", 30)))
+
+	var ce *CloseError
+
+	assert.True(t, errors.As(<-closed, &ce))
+	assert.Equal(t, strings.Repeat("再见", 20)+"再", ce.Text) // 123 bytes
+}
+
+// connectProxy tunnels the CONNECT requests, and reports the method, the host and the Proxy-Authorization.
+func connectProxy(proxied chan<- string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.Method + " " + r.Host + " " + r.Header.Get("Proxy-Authorization")
+
+		conn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		client, _, _ := w.(http.Hijacker).Hijack()
+
+		go func() { _, _ = io.Copy(conn, client) }()
+		_, _ = io.Copy(client, conn)
+	}
+}
+
+func TestWebSocketTLSProxy(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewTLSServer(echoWebSocket(t, closed))
+
+	defer ts.Close()
+
+	proxied := make(chan string, 1)
+	proxyServer := httptest.NewServer(connectProxy(proxied))
+
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	option := NewReqOption()
+	option.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	option.Proxy = http.ProxyURL(proxyURL)
+
+	testWebSocket(t, option, "wss"+strings.TrimPrefix(ts.URL, "https"), closed)
+	assert.Equal(t, "CONNECT "+strings.TrimPrefix(ts.URL, "https://")+" Basic dXNlcjpwYXNz", <-proxied)
+}
+
+func TestWebSocketProxyConfig(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(echoWebSocket(t, closed))
+
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	// the https proxy is tunneled through by CONNECT over TLS
+	proxied := make(chan string, 1)
+	proxyServer := httptest.NewTLSServer(connectProxy(proxied))
+
+	defer proxyServer.Close()
+
+	option := NewReqOption()
+	option.TLSClientConfig = proxyServer.Client().Transport.(*http.Transport).TLSClientConfig
+	option.ProxyConfig, _ = ParseProxy(proxyServer.URL, "")
+
+	testWebSocket(t, option, wsURL, closed)
+	assert.Equal(t, "CONNECT "+ts.Listener.Addr().String()+" ", <-proxied)
+
+	// the SOCKS5 proxy is dialed through
+	stub := newSOCKS5Stub(t, "", "")
+	defer stub.Close()
+
+	option = NewReqOption()
+	option.ProxyConfig, _ = ParseProxy("socks5h://"+stub.Addr(), "")
+
+	testWebSocket(t, option, wsURL, closed)
+	assert.Equal(t, []string{ts.Listener.Addr().String()}, stub.Addrs())
+
+	// the SOCKS5 proxy returned by the Proxy is dialed through too
+	option = NewReqOption()
+	option.Proxy = http.ProxyURL(&url.URL{Scheme: "socks5", Host: stub.Addr()})
+
+	testWebSocket(t, option, wsURL, closed)
+	assert.Len(t, stub.Addrs(), 2)
+
+	// the hosts of the NO_PROXY are connected directly
+	option = NewReqOption()
+	option.ProxyConfig, _ = ParseProxy(proxyServer.URL, ts.Listener.Addr().(*net.TCPAddr).IP.String())
+
+	testWebSocket(t, option, wsURL, closed)
+	assert.Len(t, proxied, 0)
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, resp, err := DialWebSocket(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+
+	var he *HTTPError
+
+	assert.True(t, errors.As(err, &he))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWebSocketKeepAlive(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		// a server which upgrades but never replies the pings
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		_ = brw.Flush()
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}))
+	defer ts.Close()
+
+	ws, _, err := DialWebSocket(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws.KeepAlive(10*time.Millisecond, 20*time.Millisecond)
+
+	_, _, err = ws.ReadMessage()
+	assert.NotNil(t, err)
+}
+
+func TestWebSocketKeepAliveStopped(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(echoWebSocket(t, closed))
+
+	defer ts.Close()
+
+	ws, _, err := DialWebSocket(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws.KeepAlive(time.Millisecond, time.Hour)
+	time.Sleep(10 * time.Millisecond) // nolint gomnd
+	assert.Nil(t, ws.Close(CloseNormalClosure, ""))
+
+	// the keepalive goroutine exits with the Close, instead of waiting for the pong timeout.
+	assert.Eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		return !strings.Contains(string(buf[:runtime.Stack(buf, true)]), "(*WebSocket).KeepAlive")
+	}, time.Second, 10*time.Millisecond)
+}